@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"bytes"
 	"github.com/benja-vq/gonkey/token"
 	"testing"
 )
@@ -36,3 +37,49 @@ func TestString(t *testing.T) {
 		t.Errorf("Wrong program string, got %s", program.String())
 	}
 }
+
+func TestDump(t *testing.T) {
+	// let add = fn(x) { x + 1 };
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let", Pos: token.Position{Line: 1, Column: 1}},
+				Name:  &Identifier{Value: "add"},
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{{Value: "x"}},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{
+								Expression: &InfixExpression{
+									Left:     &Identifier{Value: "x"},
+									Operator: "+",
+									Right:    &IntegerLiteral{Value: 1},
+								},
+							},
+						},
+					},
+					Name: "add",
+				},
+			},
+		},
+	}
+
+	expected := `Program @ 1:1
+  Statements[0]: LetStatement @ 1:1
+    Name: Identifier (Value=add)
+    Value: FunctionLiteral (Name=add)
+      Parameters[0]: Identifier (Value=x)
+      Body: BlockStatement
+        Statements[0]: ExpressionStatement
+          Expression: InfixExpression (Operator=+)
+            Left: Identifier (Value=x)
+            Right: IntegerLiteral (Value=1)
+`
+
+	var out bytes.Buffer
+	Dump(&out, program)
+
+	if out.String() != expected {
+		t.Errorf("Wrong dump, got\n%s\nwant\n%s", out.String(), expected)
+	}
+}
@@ -0,0 +1,81 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dump writes an indented tree representation of node to w, one node per
+// line followed by its non-node fields, for debugging purposes.
+func Dump(w io.Writer, node Node) {
+	dumpNode(w, "", node, 0)
+}
+
+func dumpNode(w io.Writer, label string, node Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	v := reflect.ValueOf(node)
+	if node == nil || v.Kind() == reflect.Pointer && v.IsNil() {
+		_, _ = fmt.Fprintf(w, "%s%snil\n", indent, label)
+		return
+	}
+
+	v = v.Elem()
+	t := v.Type()
+
+	var attrs []string
+	var children []func()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		switch {
+		case field.Name == "Token":
+			continue
+		case value.Type().Implements(nodeType):
+			child, _ := value.Interface().(Node)
+			name := field.Name
+			children = append(children, func() { dumpNode(w, name+": ", child, depth+1) })
+		case value.Kind() == reflect.Slice && value.Type().Elem().Implements(nodeType):
+			for j := 0; j < value.Len(); j++ {
+				child, _ := value.Index(j).Interface().(Node)
+				name := fmt.Sprintf("%s[%d]: ", field.Name, j)
+				children = append(children, func() { dumpNode(w, name, child, depth+1) })
+			}
+		case value.Kind() == reflect.Map && value.Type().Key().Implements(nodeType):
+			keys := value.MapKeys()
+			sort.Slice(keys, func(a, b int) bool {
+				return keys[a].Interface().(Node).String() < keys[b].Interface().(Node).String()
+			})
+			for _, key := range keys {
+				keyNode, _ := key.Interface().(Node)
+				valueNode, _ := value.MapIndex(key).Interface().(Node)
+				children = append(children, func() {
+					dumpNode(w, "Key: ", keyNode, depth+1)
+					dumpNode(w, "Value: ", valueNode, depth+1)
+				})
+			}
+		default:
+			attrs = append(attrs, fmt.Sprintf("%s=%v", field.Name, value.Interface()))
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "%s%s%s", indent, label, t.Name())
+	if len(attrs) > 0 {
+		_, _ = fmt.Fprintf(w, " (%s)", strings.Join(attrs, ", "))
+	}
+	if pos := node.Pos(); pos.IsValid() {
+		_, _ = fmt.Fprintf(w, " @ %s", pos)
+	}
+	_, _ = io.WriteString(w, "\n")
+
+	for _, child := range children {
+		child()
+	}
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
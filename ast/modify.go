@@ -0,0 +1,139 @@
+package ast
+
+import "slices"
+
+type ModifierFunc func(Node) Node
+
+// Modify walks the tree rooted at node depth-first, replacing every child with
+// the result of calling modifier on it, and finally calls modifier on node
+// itself. Nodes holding children are copied rather than changed, so the tree
+// passed in stays as it was and can be modified again, by a macro expanded
+// twice for instance.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch original := node.(type) {
+
+	case *Program:
+		n := copyOf(original)
+		n.Statements = modifyAll(n.Statements, modifier)
+		node = n
+	case *ExpressionStatement:
+		n := copyOf(original)
+		n.Expression, _ = Modify(n.Expression, modifier).(Expression)
+		node = n
+	case *InfixExpression:
+		n := copyOf(original)
+		n.Left, _ = Modify(n.Left, modifier).(Expression)
+		n.Right, _ = Modify(n.Right, modifier).(Expression)
+		node = n
+	case *AssignExpression:
+		n := copyOf(original)
+		n.Target, _ = Modify(n.Target, modifier).(Expression)
+		n.Value, _ = Modify(n.Value, modifier).(Expression)
+		node = n
+	case *PrefixExpression:
+		n := copyOf(original)
+		n.Right, _ = Modify(n.Right, modifier).(Expression)
+		node = n
+	case *IndexExpression:
+		n := copyOf(original)
+		n.Left, _ = Modify(n.Left, modifier).(Expression)
+		n.Index, _ = Modify(n.Index, modifier).(Expression)
+		node = n
+	case *IfExpression:
+		n := copyOf(original)
+		n.Condition, _ = Modify(n.Condition, modifier).(Expression)
+		n.Consequence, _ = Modify(n.Consequence, modifier).(*BlockStatement)
+		if n.Alternative != nil {
+			n.Alternative, _ = Modify(n.Alternative, modifier).(*BlockStatement)
+		}
+		node = n
+	case *BlockStatement:
+		n := copyOf(original)
+		n.Statements = modifyAll(n.Statements, modifier)
+		node = n
+	case *ReturnStatement:
+		n := copyOf(original)
+		n.ReturnValue, _ = Modify(n.ReturnValue, modifier).(Expression)
+		node = n
+	case *ThrowStatement:
+		n := copyOf(original)
+		n.Value, _ = Modify(n.Value, modifier).(Expression)
+		node = n
+	case *TryExpression:
+		n := copyOf(original)
+		n.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+		if n.Catch != nil {
+			n.Catch, _ = Modify(n.Catch, modifier).(*BlockStatement)
+		}
+		if n.Finally != nil {
+			n.Finally, _ = Modify(n.Finally, modifier).(*BlockStatement)
+		}
+		node = n
+	case *LetStatement:
+		n := copyOf(original)
+		n.Value, _ = Modify(n.Value, modifier).(Expression)
+		node = n
+	case *ExportStatement:
+		n := copyOf(original)
+		n.Statement, _ = Modify(n.Statement, modifier).(*LetStatement)
+		node = n
+	case *WhileStatement:
+		n := copyOf(original)
+		n.Condition, _ = Modify(n.Condition, modifier).(Expression)
+		n.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+		node = n
+	case *ForStatement:
+		n := copyOf(original)
+		n.Iterable, _ = Modify(n.Iterable, modifier).(Expression)
+		n.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+		node = n
+	case *FunctionLiteral:
+		n := copyOf(original)
+		n.Parameters = modifyAll(n.Parameters, modifier)
+		n.Body, _ = Modify(n.Body, modifier).(*BlockStatement)
+		node = n
+	case *CallExpression:
+		n := copyOf(original)
+		n.Function, _ = Modify(n.Function, modifier).(Expression)
+		n.Arguments = modifyAll(n.Arguments, modifier)
+		node = n
+	case *ArrayLiteral:
+		n := copyOf(original)
+		n.Elements = modifyAll(n.Elements, modifier)
+		node = n
+	case *InterpolatedString:
+		n := copyOf(original)
+		n.Parts = modifyAll(n.Parts, modifier)
+		node = n
+	case *HashLiteral:
+		n := copyOf(original)
+		n.Pairs = slices.Clone(n.Pairs)
+		for i, pair := range n.Pairs {
+			n.Pairs[i].Key, _ = Modify(pair.Key, modifier).(Expression)
+			n.Pairs[i].Value, _ = Modify(pair.Value, modifier).(Expression)
+		}
+		node = n
+	}
+
+	return modifier(node)
+}
+
+// copyOf returns a shallow copy of node.
+func copyOf[T any](node *T) *T {
+	copied := *node
+	return &copied
+}
+
+// modifyAll returns a new slice holding the result of modifying each of nodes.
+func modifyAll[T Node](nodes []T, modifier ModifierFunc) []T {
+	if nodes == nil {
+		return nil
+	}
+
+	modified := make([]T, len(nodes))
+	for i, n := range nodes {
+		modified[i], _ = Modify(n, modifier).(T)
+	}
+
+	return modified
+}
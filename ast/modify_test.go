@@ -0,0 +1,208 @@
+package ast
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/token"
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+
+		if integer.Value != 1 {
+			return node
+		}
+
+		integer.Value = 2
+		return integer
+	}
+
+	cases := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&AssignExpression{Target: &IndexExpression{Left: one(), Index: one()}, Operator: "=", Value: one()},
+			&AssignExpression{Target: &IndexExpression{Left: two(), Index: two()}, Operator: "=", Value: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&IfExpression{
+				Condition: one(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+			},
+			&IfExpression{
+				Condition: two(),
+				Consequence: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+				Alternative: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+			},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Value: one()},
+			&LetStatement{Value: two()},
+		},
+		{
+			&ExportStatement{Statement: &LetStatement{Value: one()}},
+			&ExportStatement{Statement: &LetStatement{Value: two()}},
+		},
+		{
+			&ThrowStatement{Value: one()},
+			&ThrowStatement{Value: two()},
+		},
+		{
+			&TryExpression{
+				Body:    &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Catch:   &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Finally: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&TryExpression{
+				Body:    &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Catch:   &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Finally: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&WhileStatement{
+				Condition: one(),
+				Body:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&WhileStatement{
+				Condition: two(),
+				Body:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&ForStatement{
+				Iterable: one(),
+				Body:     &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&ForStatement{
+				Iterable: two(),
+				Body:     &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: one()}},
+				},
+			},
+			&FunctionLiteral{
+				Parameters: []*Identifier{},
+				Body: &BlockStatement{
+					Statements: []Statement{&ExpressionStatement{Expression: two()}},
+				},
+			},
+		},
+		{
+			&CallExpression{Function: one(), Arguments: []Expression{one(), one()}},
+			&CallExpression{Function: two(), Arguments: []Expression{two(), two()}},
+		},
+		{
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Modify Test Case %d", i), func(t *testing.T) {
+			modified := Modify(c.input, turnOneIntoTwo)
+
+			if !reflect.DeepEqual(modified, c.expected) {
+				t.Errorf("Node was not modified, got %#v want %#v", modified, c.expected)
+			}
+		})
+	}
+
+	hashLiteral := &HashLiteral{
+		Pairs: []HashLiteralPair{
+			{Key: one(), Value: one()},
+			{Key: one(), Value: one()},
+		},
+	}
+
+	modifiedHash, _ := Modify(hashLiteral, turnOneIntoTwo).(*HashLiteral)
+
+	for _, pair := range modifiedHash.Pairs {
+		key, _ := pair.Key.(*IntegerLiteral)
+		if key.Value != 2 {
+			t.Errorf("Hash key was not modified, got %d want %d", key.Value, 2)
+		}
+
+		val, _ := pair.Value.(*IntegerLiteral)
+		if val.Value != 2 {
+			t.Errorf("Hash value was not modified, got %d want %d", val.Value, 2)
+		}
+	}
+}
+
+func TestModifyKeepsOriginal(t *testing.T) {
+	integer := func(value int64) *IntegerLiteral {
+		return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: fmt.Sprint(value)}, Value: value}
+	}
+	replaceOne := func(node Node) Node {
+		if literal, ok := node.(*IntegerLiteral); ok && literal.Value == 1 {
+			return integer(2)
+		}
+		return node
+	}
+
+	original := &Program{Statements: []Statement{&ExpressionStatement{
+		Expression: &CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{
+			&InfixExpression{Left: integer(1), Operator: "+", Right: integer(1)},
+			&HashLiteral{Pairs: []HashLiteralPair{{Key: integer(1), Value: integer(3)}}},
+		}},
+	}}}
+	before := original.String()
+
+	modified := Modify(original, replaceOne)
+
+	if original.String() != before {
+		t.Errorf("Original node was modified, got %s want %s", original.String(), before)
+	}
+	if modified.String() != "f((2 + 2), {2:3})" {
+		t.Errorf("Incorrect modified node, got %s want %s", modified.String(), "f((2 + 2), {2:3})")
+	}
+}
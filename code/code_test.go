@@ -0,0 +1,97 @@
+package code
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	cases := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Make Test Case %d", i), func(t *testing.T) {
+			instruction := Make(c.op, c.operands...)
+
+			if len(instruction) != len(c.expected) {
+				t.Fatalf("Instruction has wrong length, got %d want %d",
+					len(instruction), len(c.expected))
+			}
+
+			for j, b := range c.expected {
+				if instruction[j] != c.expected[j] {
+					t.Errorf("Wrong byte at pos %d, got %d want %d", j, instruction[j], b)
+				}
+			}
+		})
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("Instructions wrongly formatted, got %q want %q",
+			concatted.String(), expected)
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	cases := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Read Operands Test Case %d", i), func(t *testing.T) {
+			instruction := Make(c.op, c.operands...)
+
+			def, err := Lookup(byte(c.op))
+			if err != nil {
+				t.Fatalf("Definition not found: %q", err)
+			}
+
+			operandsRead, n := ReadOperands(def, instruction[1:])
+			if n != c.bytesRead {
+				t.Fatalf("Incorrect amount of bytes read, got %d want %d", n, c.bytesRead)
+			}
+
+			for j, want := range c.operands {
+				if operandsRead[j] != want {
+					t.Errorf("Wrong operand, got %d want %d", operandsRead[j], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package compiler
+
+import "github.com/benja-vq/gonkey/ast"
+
+// cellNames returns the names a function body assigns to, with an assignment
+// expression, as a for-in loop variable or as a catch parameter, that are also
+// referenced from a nested function. Closures capture locals by value, so the
+// locals with these names are stored in cells that closures share instead.
+//
+// The analysis only looks at names, a shadowed name may get a cell it does not
+// need, which is harmless.
+func cellNames(body *ast.BlockStatement) map[string]bool {
+	assigned := make(map[string]bool)
+	captured := make(map[string]bool)
+
+	ast.Modify(body, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.AssignExpression:
+			if ident, ok := node.Target.(*ast.Identifier); ok {
+				assigned[ident.Value] = true
+			}
+		case *ast.ForStatement:
+			assigned[node.Variable.Value] = true
+		case *ast.TryExpression:
+			if node.Parameter != nil {
+				assigned[node.Parameter.Value] = true
+			}
+		case *ast.FunctionLiteral:
+			ast.Modify(node.Body, func(inner ast.Node) ast.Node {
+				if ident, ok := inner.(*ast.Identifier); ok {
+					captured[ident.Value] = true
+				}
+				return inner
+			})
+		}
+		return node
+	})
+
+	cells := make(map[string]bool)
+	for name := range assigned {
+		if captured[name] {
+			cells[name] = true
+		}
+	}
+
+	return cells
+}
@@ -0,0 +1,892 @@
+package compiler
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/code"
+	"github.com/benja-vq/gonkey/evaluator"
+	"github.com/benja-vq/gonkey/object"
+	"github.com/benja-vq/gonkey/token"
+	"strings"
+)
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	pos token.Position // Position of the node being compiled
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           map[int]token.Position
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop     // Loops enclosing the current instruction, innermost last
+	tries               []*tryBlock // Try expressions whose handler is active, innermost last
+}
+
+// loop tracks where continue statements jump to, and the break statements
+// to patch once the end of the loop is known.
+type loop struct {
+	start  int
+	breaks []int
+}
+
+// tryBlock is a region guarded by a try expression. Jumping out of it, with
+// break, continue or return, must remove its handler and run its finally block.
+type tryBlock struct {
+	finally   *ast.BlockStatement // Nil for the region guarded by a catch block
+	loopDepth int                 // Amount of loops enclosing the try expression
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    map[int]token.Position // Source position by instruction offset
+}
+
+// Error is a compilation error, such as a reference to an undefined identifier.
+type Error struct {
+	Message string
+	Pos     token.Position
+}
+
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Message
+	}
+
+	return e.Message
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions: code.Instructions{},
+		positions:    make(map[int]token.Position),
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, name := range evaluator.BuiltinNames {
+		symbolTable.DefineBuiltin(i, name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// NewWithState creates a compiler that keeps defining symbols and constants
+// on top of the given ones, as needed by the REPL between lines.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// NewSymbolTableWithBuiltins returns a global symbol table that knows about
+// every builtin function.
+func NewSymbolTableWithBuiltins() *SymbolTable {
+	return New().symbolTable
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if pos := node.Pos(); pos.IsValid() {
+		prevPos := c.pos
+		c.pos = pos
+		defer func() { c.pos = prevPos }()
+	}
+
+	switch node := node.(type) {
+
+	case *ast.Program:
+		c.declareGlobals(node)
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		return c.compileLetStatement(node)
+	case *ast.ExportStatement:
+		return c.Compile(node.Statement)
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BreakStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return c.errorf("break outside of a loop")
+		}
+		if err := c.exitTries(len(c.scopes[c.scopeIndex].loops)); err != nil {
+			return err
+		}
+		loop.breaks = append(loop.breaks, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return c.errorf("continue outside of a loop")
+		}
+		if err := c.exitTries(len(c.scopes[c.scopeIndex].loops)); err != nil {
+			return err
+		}
+		c.emit(code.OpJump, loop.start)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.exitTries(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+	case *ast.ImportExpression:
+		c.emit(code.OpImport, c.addConstant(&object.String{Value: node.Path}))
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return c.errorf("identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return c.errorf("unknown operator: %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		op, ok := infixOperators[node.Operator]
+		if !ok {
+			return c.errorf("unknown operator: %s", node.Operator)
+		}
+		c.emit(op)
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.BooleanLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		return c.compileHashLiteral(node)
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.MacroLiteral:
+		return c.errorf("macro literals are only allowed in top-level let statements")
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			return c.errorf("quote is not supported by the vm backend")
+		}
+
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	default:
+		return c.errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+var infixOperators = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+}
+
+// compileAssignExpression leaves the assigned value on the stack. Compound
+// operators load the current value of the target before compiling the value.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	var op code.Opcode
+	if operator != "" {
+		var ok bool
+		if op, ok = infixOperators[operator]; !ok {
+			return c.errorf("unknown operator: %s", node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok && operator != "" {
+			return c.errorf("identifier not found: %s", target.Value)
+		}
+		if !ok || symbol.Scope == BuiltinScope {
+			return c.errorf("cannot assign to undeclared identifier: %s", target.Value)
+		}
+
+		if operator != "" {
+			c.loadSymbol(symbol)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if operator != "" {
+			c.emit(op)
+		}
+
+		c.emit(code.OpDup, 1)
+		return c.storeSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+
+		if operator != "" {
+			c.emit(code.OpDup, 2)
+			c.emit(code.OpIndex)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if operator != "" {
+			c.emit(op)
+		}
+
+		c.emit(code.OpSetIndex)
+		return nil
+	default:
+		return c.errorf("cannot assign to %s", node.Target.String())
+	}
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Bogus offset, patched once the consequence is compiled
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Consequence); err != nil {
+		return err
+	}
+	c.leaveValueOnStack()
+
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	afterConsequencePos := len(c.currentInstructions())
+	c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else {
+		if err := c.Compile(node.Alternative); err != nil {
+			return err
+		}
+		c.leaveValueOnStack()
+	}
+
+	afterAlternativePos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterAlternativePos)
+
+	return nil
+}
+
+// compileLogicalExpression compiles && and ||, which keep the left operand
+// on the stack and skip the right one when the left decides the result.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	c.emit(code.OpDup, 1)
+
+	// Bogus offsets, patched once the right operand is compiled
+	var skipPos int
+	if node.Operator == "&&" {
+		skipPos = c.emit(code.OpJumpNotTruthy, 9999)
+	} else {
+		rightPos := c.emit(code.OpJumpNotTruthy, 9999)
+		skipPos = c.emit(code.OpJump, 9999)
+		c.changeOperand(rightPos, len(c.currentInstructions()))
+	}
+
+	c.emit(code.OpPop)
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	c.changeOperand(skipPos, len(c.currentInstructions()))
+
+	return nil
+}
+
+// leaveValueOnStack makes a just compiled block produce a value: the trailing
+// OpPop is removed, and blocks that do not end in an expression push null.
+func (c *Compiler) leaveValueOnStack() {
+	switch {
+	case c.lastInstructionIs(code.OpPop):
+		c.removeLastPop()
+	case !c.lastInstructionIs(code.OpReturnValue):
+		c.emit(code.OpNull)
+	}
+}
+
+// compileWhileStatement compiles a loop that, like every loop, ends by pushing
+// and popping null, so that a loop evaluates to null as it does in the
+// evaluator.
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// Bogus offset, patched once the body is compiled
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	return c.compileLoopBody(start, node.Body, jumpNotTruthyPos)
+}
+
+// compileForStatement keeps the iterator in a temporary slot rather than on
+// the stack, where break and continue could not find it reliably.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+
+	iterator := c.symbolTable.DefineTemp()
+	if err := c.storeSymbol(iterator); err != nil {
+		return err
+	}
+
+	variable := c.defineSymbol(node.Variable.Value)
+
+	start := len(c.currentInstructions())
+	c.loadSymbol(iterator)
+
+	// Bogus offset, patched once the body is compiled
+	iterNextPos := c.emit(code.OpIterNext, 9999)
+
+	if err := c.storeSymbol(variable); err != nil {
+		return err
+	}
+
+	return c.compileLoopBody(start, node.Body, iterNextPos)
+}
+
+// compileLoopBody compiles body followed by a jump back to start, then points
+// the instruction at exitPos and every break statement to the end of the loop.
+func (c *Compiler) compileLoopBody(start int, body *ast.BlockStatement, exitPos int) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loop{start: start})
+
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+
+	// Compiling the body may have entered scopes, reload the current one
+	scope = &c.scopes[c.scopeIndex]
+	current := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	c.emit(code.OpJump, start)
+
+	end := len(c.currentInstructions())
+	c.changeOperand(exitPos, end)
+	for _, pos := range current.breaks {
+		c.changeOperand(pos, end)
+	}
+
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+
+	return nil
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+
+	return loops[len(loops)-1]
+}
+
+// compileTryExpression compiles a try expression with a finally block as a
+// try expression with a catch block, if any, nested in a try/finally.
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	if node.Finally == nil {
+		return c.compileTryCatch(node)
+	}
+
+	// Bogus offset, patched once the guarded code is compiled
+	tryPos := c.emit(code.OpTry, 9999)
+
+	c.enterTry(node.Finally)
+	var err error
+	if node.Catch != nil {
+		err = c.compileTryCatch(node)
+	} else {
+		err = c.compileBlockValue(node.Body)
+	}
+	c.leaveTry()
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpEndTry)
+	if err := c.Compile(node.Finally); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	// The exception is on the stack, run the finally block and raise it again
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+	if err := c.Compile(node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpThrow)
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileTryCatch(node *ast.TryExpression) error {
+	// Bogus offset, patched once the body is compiled
+	tryPos := c.emit(code.OpTry, 9999)
+
+	c.enterTry(nil)
+	err := c.compileBlockValue(node.Body)
+	c.leaveTry()
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpEndTry)
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	// The VM jumps here with the exception on the stack
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+	symbol := c.defineSymbol(node.Parameter.Value)
+	if err := c.storeSymbol(symbol); err != nil {
+		return err
+	}
+	if err := c.compileBlockValue(node.Catch); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	c.leaveValueOnStack()
+
+	return nil
+}
+
+func (c *Compiler) enterTry(finally *ast.BlockStatement) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, &tryBlock{finally: finally, loopDepth: len(scope.loops)})
+}
+
+func (c *Compiler) leaveTry() {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+}
+
+// exitTries ends the try expressions entered within the innermost loopDepth
+// loops, innermost first, before jumping out of them. A loopDepth of zero ends
+// every try expression of the function.
+func (c *Compiler) exitTries(loopDepth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+
+	for i := len(tries) - 1; i >= 0 && tries[i].loopDepth >= loopDepth; i-- {
+		c.emit(code.OpEndTry)
+		if tries[i].finally == nil {
+			continue
+		}
+
+		// The finally block runs outside of the try expressions it leaves
+		c.scopes[c.scopeIndex].tries = tries[:i:i]
+		err := c.Compile(tries[i].finally)
+		c.scopes[c.scopeIndex].tries = tries
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileHashLiteral(node *ast.HashLiteral) error {
+	for _, pair := range node.Pairs {
+		if err := c.Compile(pair.Key); err != nil {
+			return err
+		}
+		if err := c.Compile(pair.Value); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpHash, len(node.Pairs)*2)
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
+	c.symbolTable.cells = cellNames(node.Body)
+
+	for _, p := range node.Parameters {
+		c.defineSymbol(p.Value)
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+
+	// Cells are captured themselves, not the value they hold
+	for _, s := range freeSymbols {
+		switch {
+		case s.Cell && s.Scope == LocalScope:
+			c.emit(code.OpGetLocal, s.Index)
+		case s.Cell && s.Scope == FreeScope:
+			c.emit(code.OpGetFree, s.Index)
+		default:
+			c.loadSymbol(s)
+		}
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Name:          node.Name,
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Positions:     positions,
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Positions:    c.scopes[c.scopeIndex].positions,
+	}
+}
+
+// SymbolTable returns the global symbol table, to be reused with NewWithState.
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+func (c *Compiler) errorf(format string, a ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Pos: c.pos}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	scope := &c.scopes[c.scopeIndex]
+
+	posNewInstruction := len(scope.instructions)
+	scope.instructions = append(scope.instructions, ins...)
+
+	if c.pos.IsValid() {
+		scope.positions[posNewInstruction] = c.pos
+	}
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	delete(c.scopes[c.scopeIndex].positions, last.Position)
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions: code.Instructions{},
+		positions:    make(map[int]token.Position),
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex += 1
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex -= 1
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		if s.Cell {
+			c.emit(code.OpGetLocalCell, s.Index)
+		} else {
+			c.emit(code.OpGetLocal, s.Index)
+		}
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		if s.Cell {
+			c.emit(code.OpGetFreeCell, s.Index)
+		} else {
+			c.emit(code.OpGetFree, s.Index)
+		}
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// declareGlobals defines the names the top level let statements of program
+// bind, so that functions can call the ones defined after them. Names that
+// already resolve, such as builtins, are left alone until their let statement.
+func (c *Compiler) declareGlobals(program *ast.Program) {
+	if c.symbolTable.Outer != nil {
+		return
+	}
+
+	for _, s := range program.Statements {
+		if export, ok := s.(*ast.ExportStatement); ok {
+			s = export.Statement
+		}
+		let, ok := s.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		if _, ok := c.symbolTable.Resolve(let.Name.Value); !ok {
+			c.symbolTable.Define(let.Name.Value)
+		}
+	}
+}
+
+// compileLetStatement binds the value of node to its name. A name new to the
+// current scope is hidden from the value itself, which sees the binding of an
+// outer scope instead, as on the evaluator.
+func (c *Compiler) compileLetStatement(node *ast.LetStatement) error {
+	name := node.Name.Value
+
+	defining := c.symbolTable.defining
+	if symbol, ok := c.symbolTable.store[name]; !ok || (symbol.Scope != GlobalScope && symbol.Scope != LocalScope) {
+		c.symbolTable.defining = name
+	}
+	symbol := c.defineSymbol(name)
+
+	err := c.Compile(node.Value)
+	c.symbolTable.defining = defining
+	if err != nil {
+		return err
+	}
+
+	return c.storeSymbol(symbol)
+}
+
+// defineSymbol defines name in the current scope. Locals stored in a cell get
+// it right away, so that closures created before the first assignment to the
+// local share the cell too.
+func (c *Compiler) defineSymbol(name string) Symbol {
+	symbol := c.symbolTable.Define(name)
+	if symbol.Cell {
+		c.emit(code.OpMakeCell, symbol.Index)
+	}
+
+	return symbol
+}
+
+// storeSymbol pops the top of the stack into the slot of s.
+func (c *Compiler) storeSymbol(s Symbol) error {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case s.Scope == LocalScope && s.Cell:
+		c.emit(code.OpSetLocalCell, s.Index)
+	case s.Scope == LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case s.Scope == FreeScope && s.Cell:
+		c.emit(code.OpSetFreeCell, s.Index)
+	case s.Scope == FunctionScope:
+		return c.errorf("cannot assign to function %s inside its own body", s.Name)
+	default:
+		return c.errorf("cannot assign to %s", s.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package compiler
+
+import (
+	"maps"
+	"slices"
+	"sort"
+)
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Cell  bool // The slot holds an *object.Cell instead of the value itself
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+	cells          map[string]bool // Names of the locals to store in cells
+	defining       string          // Name a let statement introduces, hidden from its own value
+
+	FreeSymbols []Symbol
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:       make(map[string]Symbol),
+		FreeSymbols: []Symbol{},
+	}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Clone returns a copy of the table sharing its outer tables, so that the
+// definitions of a compilation that fails can be dropped with the copy.
+func (s *SymbolTable) Clone() *SymbolTable {
+	clone := *s
+	clone.store = maps.Clone(s.store)
+	clone.cells = maps.Clone(s.cells)
+	clone.FreeSymbols = slices.Clone(s.FreeSymbols)
+
+	return &clone
+}
+
+// Define allocates a slot for name in this table. Redefining a name that
+// already has a slot in this table reuses it.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && (symbol.Scope == GlobalScope || symbol.Scope == LocalScope) {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+		symbol.Cell = s.cells[name]
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions += 1
+	return symbol
+}
+
+// DefineTemp allocates an unnamed slot for a value the compiler keeps around,
+// such as the iterator of a for-in loop.
+func (s *SymbolTable) DefineTemp() Symbol {
+	symbol := Symbol{Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.numDefinitions += 1
+	return symbol
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// DefineFunctionName defines the name a function literal is bound to, so the
+// function can refer to itself without capturing a free variable.
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Resolve looks name up in this table and its outer tables. Local symbols of
+// an enclosing function are turned into free symbols of this one.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	return s.resolve(name, true)
+}
+
+// resolve looks name up, skipping the name being defined in this table when
+// direct is set: the value of `let a = a + 1` sees the outer a, functions
+// created in it the new one.
+func (s *SymbolTable) resolve(name string, direct bool) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if ok && direct && name == s.defining {
+		ok = false
+	}
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.resolve(name, false)
+		if !ok {
+			return obj, ok
+		}
+
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+			return obj, ok
+		}
+
+		free := s.defineFree(obj)
+		return free, true
+	}
+
+	return obj, ok
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Cell: original.Cell}
+	symbol.Scope = FreeScope
+
+	// The local being defined keeps its name, its value alone sees the free one
+	if _, ok := s.store[original.Name]; !ok {
+		s.store[original.Name] = symbol
+	}
+	return symbol
+}
+
+// NumDefinitions returns the amount of global or local slots the table needs.
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
+
+// Symbols returns the symbols defined with Define in this table, in the order
+// of their indexes.
+func (s *SymbolTable) Symbols() []Symbol {
+	symbols := make([]Symbol, 0, s.numDefinitions)
+	for _, symbol := range s.store {
+		if symbol.Scope == GlobalScope || symbol.Scope == LocalScope {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Index < symbols[j].Index
+	})
+
+	return symbols
+}
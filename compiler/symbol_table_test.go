@@ -0,0 +1,121 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("Incorrect symbol, got %+v want %+v", a, expected["a"])
+	}
+
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("Incorrect symbol, got %+v want %+v", b, expected["b"])
+	}
+
+	if redefined := global.Define("a"); redefined != expected["a"] {
+		t.Errorf("Redefinition did not reuse the slot, got %+v want %+v", redefined, expected["a"])
+	}
+
+	local := NewEnclosedSymbolTable(global)
+
+	if c := local.Define("c"); c != expected["c"] {
+		t.Errorf("Incorrect symbol, got %+v want %+v", c, expected["c"])
+	}
+
+	if d := local.Define("d"); d != expected["d"] {
+		t.Errorf("Incorrect symbol, got %+v want %+v", d, expected["d"])
+	}
+}
+
+func TestClone(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+
+	clone := global.Clone()
+	clone.Define("b")
+
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("Definition in the clone changed the table")
+	}
+
+	if global.NumDefinitions() != 1 || clone.NumDefinitions() != 2 {
+		t.Errorf("Incorrect number of definitions, got %d and %d want 1 and 2",
+			global.NumDefinitions(), clone.NumDefinitions())
+	}
+
+	if resolved, ok := clone.Resolve("a"); !ok || resolved != a {
+		t.Errorf("Incorrect symbol in the clone, got %+v want %+v", resolved, a)
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("b")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("c")
+
+	cases := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"len", Symbol{Name: "len", Scope: BuiltinScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: FreeScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+	}
+
+	for _, c := range cases {
+		result, ok := secondLocal.Resolve(c.name)
+		if !ok {
+			t.Errorf("Name %s not resolvable", c.name)
+			continue
+		}
+
+		if result != c.expected {
+			t.Errorf("Incorrect symbol for %s, got %+v want %+v", c.name, result, c.expected)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 {
+		t.Fatalf("Incorrect amount of free symbols, got %d want %d",
+			len(secondLocal.FreeSymbols), 1)
+	}
+
+	expectedFree := Symbol{Name: "b", Scope: LocalScope, Index: 0}
+	if secondLocal.FreeSymbols[0] != expectedFree {
+		t.Errorf("Incorrect free symbol, got %+v want %+v", secondLocal.FreeSymbols[0], expectedFree)
+	}
+
+	if _, ok := secondLocal.Resolve("unknown"); ok {
+		t.Errorf("Name unknown resolved, but was not defined")
+	}
+}
+
+func TestDefineAndResolveFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineFunctionName("a")
+
+	expected := Symbol{Name: "a", Scope: FunctionScope, Index: 0}
+
+	result, ok := global.Resolve(expected.Name)
+	if !ok {
+		t.Fatalf("Function name %s not resolvable", expected.Name)
+	}
+
+	if result != expected {
+		t.Errorf("Incorrect symbol, got %+v want %+v", result, expected)
+	}
+}
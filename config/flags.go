@@ -1,3 +1,29 @@
 package config
 
 var Debug *bool
+
+// Engine selects the backend programs are executed with
+var Engine *string
+
+// TraceDepth caps the number of calls recorded in the stack trace of an error
+var TraceDepth *int
+
+const (
+	EngineEval = "eval" // Tree-walking evaluator
+	EngineVM   = "vm"   // Bytecode compiler and virtual machine
+)
+
+const DefaultTraceDepth = 50
+
+func UseVM() bool {
+	return Engine != nil && *Engine == EngineVM
+}
+
+// MaxTraceDepth returns the configured trace depth, 0 meaning no cap.
+func MaxTraceDepth() int {
+	if TraceDepth == nil {
+		return DefaultTraceDepth
+	}
+
+	return *TraceDepth
+}
@@ -0,0 +1,74 @@
+package evaluator
+
+import (
+	"github.com/benja-vq/gonkey/object"
+	"sort"
+)
+
+// The functions in this file expose the evaluator's semantics to the bytecode
+// virtual machine, so that both backends agree on operators and builtins.
+
+// BuiltinNames lists the names of every builtin in a stable order. The
+// compiler refers to builtins by their index in this slice.
+var BuiltinNames = builtinNames()
+
+func builtinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GetBuiltin returns the builtin function registered under name.
+func GetBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// ApplyFunction calls fn, a Monkey function or a builtin, with args within
+// the limits of limiter.
+func ApplyFunction(fn object.Object, args []object.Object, limiter *object.Limiter) object.Object {
+	return applyFunction(fn, args, limiter)
+}
+
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// Interpolate joins the values of the parts of an interpolated string.
+func Interpolate(parts []object.Object) object.Object {
+	return interpolate(parts)
+}
+
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+func SetIndex(left, index, val object.Object) object.Object {
+	return evalIndexAssignment(left, index, val)
+}
+
+// IterableElements returns the values a for-in loop over obj visits.
+func IterableElements(obj object.Object) ([]object.Object, *object.Error) {
+	return iterableElements(obj)
+}
+
+// ThrownError returns the error raised by throwing val.
+func ThrownError(val object.Object) *object.Error {
+	return newThrownError(val)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+func NativeBoolToBooleanObject(input bool) *object.Boolean {
+	return nativeBoolToBooleanObject(input)
+}
@@ -3,23 +3,29 @@ package evaluator
 import (
 	"fmt"
 	"github.com/benja-vq/gonkey/object"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d want %d",
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
 					len(args), 1)
 			}
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
-				return newError("argument to 'len' not supported, got %s",
+				return newError(object.TYPE_ERROR, "argument to 'len' not supported, got %s",
 					arg.Type())
 			}
 		},
@@ -27,12 +33,12 @@ var builtins = map[string]*object.Builtin{
 	"first": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d want %d",
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
 					len(args), 1)
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'first' must be ARRAY, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to 'first' must be ARRAY, got %s", args[0].Type())
 			}
 
 			arr := args[0].(*object.Array)
@@ -46,12 +52,12 @@ var builtins = map[string]*object.Builtin{
 	"last": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d want %d",
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
 					len(args), 1)
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'last' must be ARRAY, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to 'last' must be ARRAY, got %s", args[0].Type())
 			}
 
 			arr := args[0].(*object.Array)
@@ -66,12 +72,12 @@ var builtins = map[string]*object.Builtin{
 	"rest": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d want %d",
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
 					len(args), 1)
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'rest' must be ARRAY, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to 'rest' must be ARRAY, got %s", args[0].Type())
 			}
 
 			arr := args[0].(*object.Array)
@@ -86,18 +92,21 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": {
-		Fn: func(args ...object.Object) object.Object {
+		LimitedFn: func(limiter *object.Limiter, _ object.CallFunction, args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments, got %d want %d",
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
 					len(args), 2)
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'push' must be ARRAY, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to 'push' must be ARRAY, got %s", args[0].Type())
 			}
 
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
+			if err := limiter.CheckLength(object.ARRAY_OBJ, int64(length)+1); err != nil {
+				return err
+			}
 
 			newElements := make([]object.Object, length+1)
 			copy(newElements, arr.Elements)
@@ -106,6 +115,737 @@ var builtins = map[string]*object.Builtin{
 			return &object.Array{Elements: newElements}
 		},
 	},
+	"slice": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want 2 or 3",
+					len(args))
+			}
+
+			bounds := make([]int64, len(args)-1)
+			for i, arg := range args[1:] {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument %d to 'slice' must be INTEGER, got %s",
+						i+2, arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+
+			switch arg := args[0].(type) {
+			case *object.String:
+				start, end := sliceBounds(bounds, int64(utf8.RuneCountInString(arg.Value)))
+				startOffset := charOffset(arg.Value, start)
+				endOffset := startOffset + charOffset(arg.Value[startOffset:], end-start)
+				return &object.String{Value: arg.Value[startOffset:endOffset]}
+			case *object.Array:
+				start, end := sliceBounds(bounds, int64(len(arg.Elements)))
+				newElements := make([]object.Object, end-start)
+				copy(newElements, arg.Elements[start:end])
+				return &object.Array{Elements: newElements}
+			default:
+				return newError(object.TYPE_ERROR, "argument to 'slice' must be ARRAY or STRING, got %s",
+					arg.Type())
+			}
+		},
+	},
+	"bytes": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+					len(args), 1)
+			}
+
+			if args[0].Type() != object.STRING_OBJ {
+				return newError(object.TYPE_ERROR, "argument to 'bytes' must be STRING, got %s", args[0].Type())
+			}
+
+			str := args[0].(*object.String)
+			elements := make([]object.Object, len(str.Value))
+			for i := 0; i < len(str.Value); i++ {
+				elements[i] = &object.Integer{Value: int64(str.Value[i])}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"split": {
+		LimitedFn: func(limiter *object.Limiter, _ object.CallFunction, args ...object.Object) object.Object {
+			if err := checkArguments("split", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			str, sep := stringValue(args[0]), stringValue(args[1])
+			length := int64(strings.Count(str, sep) + 1)
+			if sep == "" {
+				length = int64(utf8.RuneCountInString(str))
+			}
+			if err := limiter.CheckLength(object.ARRAY_OBJ, length); err != nil {
+				return err
+			}
+
+			parts := strings.Split(str, sep)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"join": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("join", args, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			parts := make([]string, len(elements))
+			for i, el := range elements {
+				parts[i] = el.Inspect()
+			}
+
+			return &object.String{Value: strings.Join(parts, stringValue(args[1]))}
+		},
+	},
+	"trim": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("trim", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.TrimSpace(stringValue(args[0]))}
+		},
+	},
+	"upper": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("upper", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.ToUpper(stringValue(args[0]))}
+		},
+	},
+	"lower": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("lower", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.ToLower(stringValue(args[0]))}
+		},
+	},
+	"contains": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("contains", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return nativeBoolToBooleanObject(strings.Contains(stringValue(args[0]), stringValue(args[1])))
+		},
+	},
+	"starts_with": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("starts_with", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return nativeBoolToBooleanObject(strings.HasPrefix(stringValue(args[0]), stringValue(args[1])))
+		},
+	},
+	"ends_with": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("ends_with", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return nativeBoolToBooleanObject(strings.HasSuffix(stringValue(args[0]), stringValue(args[1])))
+		},
+	},
+	"index_of": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+					len(args), 2)
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				for i, el := range arg.Elements {
+					if object.Equal(el, args[1]) {
+						return &object.Integer{Value: int64(i)}
+					}
+				}
+				return &object.Integer{Value: -1}
+			case *object.String:
+				if err := checkArguments("index_of", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+					return err
+				}
+
+				offset := strings.Index(arg.Value, stringValue(args[1]))
+				if offset < 0 {
+					return &object.Integer{Value: -1}
+				}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value[:offset]))}
+			default:
+				return newError(object.TYPE_ERROR, "argument 1 to 'index_of' must be ARRAY or STRING, got %s",
+					arg.Type())
+			}
+		},
+	},
+	"replace": {
+		Fn: func(args ...object.Object) object.Object {
+			err := checkArguments("replace", args, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ)
+			if err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.ReplaceAll(stringValue(args[0]), stringValue(args[1]), stringValue(args[2]))}
+		},
+	},
+	"repeat": {
+		LimitedFn: func(limiter *object.Limiter, _ object.CallFunction, args ...object.Object) object.Object {
+			if err := checkArguments("repeat", args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			str, count := stringValue(args[0]), args[1].(*object.Integer).Value
+			if count < 0 {
+				return newError(object.ARGUMENT_ERROR, "argument 2 to 'repeat' must not be negative, got %d", count)
+			}
+			if len(str) > 0 && count > math.MaxInt32/int64(len(str)) {
+				return newError(object.ARGUMENT_ERROR, "result of 'repeat' too large, got %d times %d bytes",
+					count, len(str))
+			}
+			if err := limiter.CheckLength(object.STRING_OBJ, count*int64(len(str))); err != nil {
+				return err
+			}
+
+			return &object.String{Value: strings.Repeat(str, int(count))}
+		},
+	},
+	"substr": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want 2 or 3",
+					len(args))
+			}
+
+			types := []object.ObjectType{object.STRING_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ}
+			if err := checkArguments("substr", args, types[:len(args)]...); err != nil {
+				return err
+			}
+
+			str := stringValue(args[0])
+			start := max(args[1].(*object.Integer).Value, 0)
+			startOffset := charOffset(str, start)
+
+			endOffset := len(str)
+			if len(args) == 3 {
+				length := max(args[2].(*object.Integer).Value, 0)
+				endOffset = startOffset + charOffset(str[startOffset:], length)
+			}
+
+			return &object.String{Value: str[startOffset:endOffset]}
+		},
+	},
+	"format": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got 0 want at least 1")
+			}
+			if err := checkArguments("format", args[:1], object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			values := make([]any, len(args)-1)
+			for i, arg := range args[1:] {
+				values[i] = formatValue(arg)
+			}
+
+			return &object.String{Value: fmt.Sprintf(stringValue(args[0]), values...)}
+		},
+	},
+	"map": {
+		LimitedFn: func(limiter *object.Limiter, call object.CallFunction, args ...object.Object) object.Object {
+			if err := checkCallback("map", args); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			if err := limiter.CheckLength(object.ARRAY_OBJ, int64(len(elements))); err != nil {
+				return err
+			}
+			mapped := make([]object.Object, len(elements))
+			for i, el := range elements {
+				result := call(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				mapped[i] = result
+			}
+
+			return &object.Array{Elements: mapped}
+		},
+	},
+	"filter": {
+		HigherOrderFn: func(call object.CallFunction, args ...object.Object) object.Object {
+			if err := checkCallback("filter", args); err != nil {
+				return err
+			}
+
+			kept := []object.Object{}
+			for _, el := range args[0].(*object.Array).Elements {
+				result := call(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					kept = append(kept, el)
+				}
+			}
+
+			return &object.Array{Elements: kept}
+		},
+	},
+	"reduce": {
+		HigherOrderFn: func(call object.CallFunction, args ...object.Object) object.Object {
+			if len(args) != 2 && len(args) != 3 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want 2 or 3",
+					len(args))
+			}
+			if err := checkCallback("reduce", args[:2]); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			var acc object.Object = NULL
+			if len(args) == 3 {
+				acc = args[2]
+			} else if len(elements) > 0 {
+				acc, elements = elements[0], elements[1:]
+			}
+
+			for _, el := range elements {
+				acc = call(args[1], []object.Object{acc, el})
+				if isError(acc) {
+					return acc
+				}
+			}
+
+			return acc
+		},
+	},
+	"each": {
+		HigherOrderFn: func(call object.CallFunction, args ...object.Object) object.Object {
+			if err := checkCallback("each", args); err != nil {
+				return err
+			}
+
+			for _, el := range args[0].(*object.Array).Elements {
+				if result := call(args[1], []object.Object{el}); isError(result) {
+					return result
+				}
+			}
+
+			return NULL
+		},
+	},
+	"find": {
+		HigherOrderFn: func(call object.CallFunction, args ...object.Object) object.Object {
+			if err := checkCallback("find", args); err != nil {
+				return err
+			}
+
+			for _, el := range args[0].(*object.Array).Elements {
+				result := call(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return el
+				}
+			}
+
+			return NULL
+		},
+	},
+	"any": {
+		HigherOrderFn: func(call object.CallFunction, args ...object.Object) object.Object {
+			if err := checkCallback("any", args); err != nil {
+				return err
+			}
+
+			for _, el := range args[0].(*object.Array).Elements {
+				result := call(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				if isTruthy(result) {
+					return TRUE
+				}
+			}
+
+			return FALSE
+		},
+	},
+	"all": {
+		HigherOrderFn: func(call object.CallFunction, args ...object.Object) object.Object {
+			if err := checkCallback("all", args); err != nil {
+				return err
+			}
+
+			for _, el := range args[0].(*object.Array).Elements {
+				result := call(args[1], []object.Object{el})
+				if isError(result) {
+					return result
+				}
+				if !isTruthy(result) {
+					return FALSE
+				}
+			}
+
+			return TRUE
+		},
+	},
+	"sort": {
+		HigherOrderFn: func(call object.CallFunction, args ...object.Object) object.Object {
+			if len(args) == 2 {
+				if err := checkCallback("sort", args); err != nil {
+					return err
+				}
+			} else if err := checkArguments("sort", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			// The comparator reports whether its first argument goes before
+			// the second, < does without one
+			less := func(a, b object.Object) object.Object {
+				return evalInfixExpression("<", a, b)
+			}
+			if len(args) == 2 {
+				less = func(a, b object.Object) object.Object {
+					return call(args[1], []object.Object{a, b})
+				}
+			}
+
+			sorted := make([]object.Object, len(args[0].(*object.Array).Elements))
+			copy(sorted, args[0].(*object.Array).Elements)
+
+			var err object.Object
+			sort.SliceStable(sorted, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+
+				result := less(sorted[i], sorted[j])
+				if isError(result) {
+					err = result
+					return false
+				}
+				return isTruthy(result)
+			})
+			if err != nil {
+				return err
+			}
+
+			return &object.Array{Elements: sorted}
+		},
+	},
+	"reverse": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("reverse", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			reversed := make([]object.Object, len(elements))
+			for i, el := range elements {
+				reversed[len(elements)-1-i] = el
+			}
+
+			return &object.Array{Elements: reversed}
+		},
+	},
+	"concat": {
+		LimitedFn: func(limiter *object.Limiter, _ object.CallFunction, args ...object.Object) object.Object {
+			var length int64
+			for i, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument %d to 'concat' must be ARRAY, got %s",
+						i+1, arg.Type())
+				}
+				length += int64(len(arr.Elements))
+			}
+			if err := limiter.CheckLength(object.ARRAY_OBJ, length); err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, 0, length)
+			for _, arg := range args {
+				elements = append(elements, arg.(*object.Array).Elements...)
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"flatten": {
+		LimitedFn: func(limiter *object.Limiter, _ object.CallFunction, args ...object.Object) object.Object {
+			if err := checkArguments("flatten", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			var length int64
+			for _, el := range args[0].(*object.Array).Elements {
+				if arr, ok := el.(*object.Array); ok {
+					length += int64(len(arr.Elements))
+				} else {
+					length += 1
+				}
+			}
+			if err := limiter.CheckLength(object.ARRAY_OBJ, length); err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, 0, length)
+			for _, el := range args[0].(*object.Array).Elements {
+				if arr, ok := el.(*object.Array); ok {
+					elements = append(elements, arr.Elements...)
+				} else {
+					elements = append(elements, el)
+				}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"zip": {
+		LimitedFn: func(limiter *object.Limiter, _ object.CallFunction, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got 0 want at least 1")
+			}
+
+			length := math.MaxInt
+			for i, arg := range args {
+				arr, ok := arg.(*object.Array)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument %d to 'zip' must be ARRAY, got %s",
+						i+1, arg.Type())
+				}
+				length = min(length, len(arr.Elements))
+			}
+			if err := limiter.CheckLength(object.ARRAY_OBJ, int64(len(args))); err != nil {
+				return err
+			}
+			if err := limiter.CheckLength(object.ARRAY_OBJ, int64(length)); err != nil {
+				return err
+			}
+
+			tuples := make([]object.Object, length)
+			for i := range tuples {
+				tuple := make([]object.Object, len(args))
+				for j, arg := range args {
+					tuple[j] = arg.(*object.Array).Elements[i]
+				}
+				tuples[i] = &object.Array{Elements: tuple}
+			}
+
+			return &object.Array{Elements: tuples}
+		},
+	},
+	"range": {
+		LimitedFn: func(limiter *object.Limiter, _ object.CallFunction, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 3 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want 1 to 3",
+					len(args))
+			}
+
+			types := []object.ObjectType{object.INTEGER_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ}
+			if err := checkArguments("range", args, types[:len(args)]...); err != nil {
+				return err
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				bounds[i] = arg.(*object.Integer).Value
+			}
+
+			start, end, step := int64(0), bounds[0], int64(1)
+			if len(bounds) > 1 {
+				start, end = bounds[0], bounds[1]
+			}
+			if len(bounds) > 2 {
+				step = bounds[2]
+			}
+			if step == 0 {
+				return newError(object.ARGUMENT_ERROR, "argument 3 to 'range' must not be 0")
+			}
+
+			var length int64
+			if step > 0 && end > start {
+				length = (end - start + step - 1) / step
+			} else if step < 0 && end < start {
+				length = (start - end - step - 1) / -step
+			}
+			if length > math.MaxInt32 {
+				return newError(object.ARGUMENT_ERROR, "result of 'range' too large, got %d elements", length)
+			}
+			if err := limiter.CheckLength(object.ARRAY_OBJ, length); err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, length)
+			for i := range elements {
+				elements[i] = &object.Integer{Value: start + int64(i)*step}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"unique": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("unique", args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			kept := []object.Object{}
+			seen := object.NewHash(0)
+			for _, el := range args[0].(*object.Array).Elements {
+				if key, ok := object.AsHashable(el); ok {
+					if _, ok := seen.Get(key); !ok {
+						seen.Set(key, TRUE)
+						kept = append(kept, el)
+					}
+					continue
+				}
+
+				if !containsEqual(kept, el) {
+					kept = append(kept, el)
+				}
+			}
+
+			return &object.Array{Elements: kept}
+		},
+	},
+	"keys": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("keys", args, object.HASH_OBJ); err != nil {
+				return err
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Key
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"values": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("values", args, object.HASH_OBJ); err != nil {
+				return err
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.Value
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"items": {
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArguments("items", args, object.HASH_OBJ); err != nil {
+				return err
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elements := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"has": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+					len(args), 2)
+			}
+
+			hash, key, err := hashAndKey("has", args)
+			if err != nil {
+				return err
+			}
+
+			_, ok := hash.Get(key)
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+	"get": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want 2 or 3",
+					len(args))
+			}
+
+			hash, key, err := hashAndKey("get", args)
+			if err != nil {
+				return err
+			}
+
+			if value, ok := hash.Get(key); ok {
+				return value
+			}
+			if len(args) == 3 {
+				return args[2]
+			}
+
+			return NULL
+		},
+	},
+	"delete": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+					len(args), 2)
+			}
+
+			hash, key, err := hashAndKey("delete", args)
+			if err != nil {
+				return err
+			}
+
+			kept := hash.Copy()
+			kept.Delete(key)
+
+			return kept
+		},
+	},
+	"merge": {
+		Fn: func(args ...object.Object) object.Object {
+			merged := object.NewHash(0)
+			for i, arg := range args {
+				hash, ok := arg.(*object.Hash)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument %d to 'merge' must be HASH, got %s",
+						i+1, arg.Type())
+				}
+				for _, pair := range hash.Pairs() {
+					merged.Set(pair.Key, pair.Value)
+				}
+			}
+
+			return merged
+		},
+	},
 	"puts": {
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
@@ -116,3 +856,104 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 }
+
+// sliceBounds returns the start and end of a slice of a collection of length
+// elements, bounds holding the requested start and optional end, both clamped
+// to the collection.
+func sliceBounds(bounds []int64, length int64) (start, end int64) {
+	start, end = bounds[0], length
+	if len(bounds) > 1 {
+		end = bounds[1]
+	}
+
+	end = min(max(end, 0), length)
+	start = min(max(start, 0), end)
+
+	return start, end
+}
+
+// checkArguments returns an error unless args holds as many arguments as
+// types, each of the type at the same position, name being the builtin they
+// are passed to.
+func checkArguments(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+			len(args), len(types))
+	}
+
+	for i, arg := range args {
+		if arg.Type() != types[i] {
+			return newError(object.TYPE_ERROR, "argument %d to '%s' must be %s, got %s",
+				i+1, name, types[i], arg.Type())
+		}
+	}
+
+	return nil
+}
+
+// hashAndKey returns the hash and the key to look up in it that args start
+// with, name being the builtin they are passed to.
+func hashAndKey(name string, args []object.Object) (*object.Hash, object.Hashable, *object.Error) {
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return nil, nil, newError(object.TYPE_ERROR, "argument 1 to '%s' must be HASH, got %s",
+			name, args[0].Type())
+	}
+
+	key, ok := object.AsHashable(args[1])
+	if !ok {
+		return nil, nil, newError(object.TYPE_ERROR, "%s is not usable as a hash key", args[1].Type())
+	}
+
+	return hash, key, nil
+}
+
+func stringValue(obj object.Object) string {
+	return obj.(*object.String).Value
+}
+
+// formatValue returns the Go value the verbs of 'format' see for obj, the
+// values of numbers, strings and booleans and what puts shows for the others.
+func formatValue(obj object.Object) any {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	default:
+		return obj.Inspect()
+	}
+}
+
+// checkCallback returns an error unless args holds an array and a function to
+// call on its elements, name being the builtin they are passed to.
+func checkCallback(name string, args []object.Object) *object.Error {
+	if len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+			len(args), 2)
+	}
+
+	if args[0].Type() != object.ARRAY_OBJ {
+		return newError(object.TYPE_ERROR, "argument 1 to '%s' must be ARRAY, got %s", name, args[0].Type())
+	}
+
+	if fn := args[1].Type(); fn != object.FUNCTION_OBJ && fn != object.BUILTIN_OBJ {
+		return newError(object.TYPE_ERROR, "argument 2 to '%s' must be FUNCTION, got %s", name, fn)
+	}
+
+	return nil
+}
+
+func containsEqual(elements []object.Object, obj object.Object) bool {
+	for _, el := range elements {
+		if object.Equal(el, obj) {
+			return true
+		}
+	}
+
+	return false
+}
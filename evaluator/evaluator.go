@@ -1,30 +1,79 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/config"
 	"github.com/benja-vq/gonkey/object"
+	"github.com/benja-vq/gonkey/token"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
+// Eval evaluates node in env within the limits of env's limiter, which are
+// object.DefaultLimits unless node is evaluated by EvalContext. Errors raised
+// while evaluating node that do not carry a position yet are stamped with the
+// position of node.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return evalNode(node, env, false)
+}
+
+// evalTail evaluates node in tail position, where the call to a Monkey
+// function node ends with is returned as an object.TailCall for the calling
+// function to perform once its own body is done.
+func evalTail(node ast.Node, env *object.Environment) object.Object {
+	return evalNode(node, env, true)
+}
+
+func evalNode(node ast.Node, env *object.Environment, tail bool) object.Object {
+	var result object.Object
+	if err := env.Limiter().Step(); err != nil {
+		result = err
+	} else if result = eval(node, env, tail); result != nil {
+		if err := env.Limiter().CheckSize(result); err != nil {
+			result = err
+		}
+	}
+
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+
+	return result
+}
+
+// EvalContext evaluates node in env like Eval, raising a LimitError when ctx
+// is done or when the program goes over limits.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits object.Limits) object.Object {
+	stop := env.Limiter().Start(ctx, limits)
+	defer stop()
+
+	return Eval(node, env)
+}
+
+func eval(node ast.Node, env *object.Environment, tail bool) object.Object {
 	switch node := node.(type) {
 
 	case *ast.Program:
 		return evalProgram(node, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return evalNode(node.Expression, env, tail)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, env, tail)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return evalIfExpression(node, env, tail)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := evalTail(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
@@ -35,34 +84,51 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 		env.Set(node.Name.Value, val)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return newThrownError(val)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.ImportExpression:
+		return evalImportExpression(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Name: node.Name, Parameters: params, Body: body, Env: env}
+	case *ast.MacroLiteral:
+		// DefineMacros takes the ones of top level let statements out
+		return newError(object.RUNTIME_ERROR, "macro literals are only allowed in top-level let statements")
 	case *ast.CallExpression:
-		if node.Function.TokenLiteral() == "quote" {
-			return quote(node.Arguments[0])
-		}
-
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-
-		return applyFunction(function, args)
+		return evalCallExpression(node, env, tail)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.BooleanLiteral:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		parts := evalExpressions(node.Parts, env)
+		if len(parts) == 1 && isError(parts[0]) {
+			return parts[0]
+		}
+		return interpolate(parts)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
@@ -78,6 +144,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env, tail)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -97,6 +166,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	}
 
 	return nil
@@ -110,7 +181,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
+			return unwrapReturnValue(finishTailCall(result))
 		case *object.Error:
 			return result
 		}
@@ -119,15 +190,15 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
 	var result object.Object
 
-	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+	for i, statement := range block.Statements {
+		result = evalNode(statement, env, tail && i == len(block.Statements)-1)
 
 		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 				return result
 			}
 		}
@@ -151,7 +222,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -169,29 +240,33 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s",
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s",
 			left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
@@ -208,6 +283,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError(object.RUNTIME_ERROR, "division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -218,50 +296,124 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression handles float operands, promoting an integer operand
+// to a float when the other one is a float.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	rt := obj.Type()
+	return rt == object.INTEGER_OBJ || rt == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
+	}
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
 	switch operator {
 	case "+":
-		leftVal := left.(*object.String).Value
-		rightVal := right.(*object.String).Value
 		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s",
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment, tail bool) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return evalNode(ie.Consequence, env, tail)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return evalNode(ie.Alternative, env, tail)
 	} else {
 		return NULL
 	}
 }
 
+// evalLogicalExpression evaluates && and ||, which return the left operand
+// when it decides the result, falsy for && and truthy for ||, without
+// evaluating the right one.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment, tail bool) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if isTruthy(left) == (node.Operator == "||") {
+		return left
+	}
+
+	return evalNode(node.Right, env, tail)
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
+	if builtin, ok := env.Builtin(node.Value); ok {
+		return builtin
+	}
+
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NAME_ERROR, "identifier not found: "+node.Value)
 }
 
 func evalExpressions(exprs []ast.Expression, env *object.Environment) []object.Object {
@@ -278,19 +430,146 @@ func evalExpressions(exprs []ast.Expression, env *object.Environment) []object.O
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalCallExpression applies the called function, unless the call is in tail
+// position and the function a Monkey one, in which case the call is left to
+// the function whose body is being evaluated.
+func evalCallExpression(node *ast.CallExpression, env *object.Environment, tail bool) object.Object {
+	if node.Function.TokenLiteral() == "quote" {
+		return quote(node, env)
+	}
+
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if fn, ok := function.(*object.Function); ok && tail {
+		return &object.TailCall{Function: fn, Arguments: args, Pos: node.Pos()}
+	}
+
+	if builtin, ok := function.(*object.Builtin); ok {
+		return builtin.Call(env.Limiter(), tracedCall(node.Pos(), env.Limiter()), args...)
+	}
+
+	return tracedCall(node.Pos(), env.Limiter())(function, args)
+}
+
+// tracedCall returns a function applying functions within the limits of
+// limiter that records their calls in the stack traces of the errors they
+// return as made at pos, for the calls of a call expression and those of the
+// builtins it calls.
+func tracedCall(pos token.Position, limiter *object.Limiter) object.CallFunction {
+	return func(function object.Object, args []object.Object) object.Object {
+		fn, ok := function.(*object.Function)
+		if !ok {
+			return applyFunction(function, args, limiter)
+		}
+
+		calls := fn.Env.Calls()
+		calls.Push(object.Call{Function: fn, Args: args, Pos: pos})
+		result := applyFunction(function, args, limiter)
+		calls.Pop()
+
+		traceCall(result, fn, args, pos)
+		return result
+	}
+}
+
+// traceCall records the call of fn at pos in the stack trace of result when
+// result is an error.
+func traceCall(result object.Object, fn *object.Function, args []object.Object, pos token.Position) {
+	err, ok := result.(*object.Error)
+	if !ok {
+		return
+	}
+
+	if !err.Pos.IsValid() {
+		err.Pos = pos
+	}
+	err.PushFrame(object.StackFrame{
+		Function: fn.Name,
+		Pos:      pos,
+		Args:     object.SummarizeArgs(args),
+	}, config.MaxTraceDepth())
+}
+
+func applyFunction(fn object.Object, args []object.Object, limiter *object.Limiter) object.Object {
 
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if err := limiter.Enter(); err != nil {
+			return err
+		}
+		defer limiter.Leave()
+
+		return callFunction(fn, args)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return fn.Call(limiter, func(function object.Object, args []object.Object) object.Object {
+			return applyFunction(function, args, limiter)
+		}, args...)
 	default:
-		return newError("%s is not a function", fn.Type())
+		return newError(object.TYPE_ERROR, "%s is not a function", fn.Type())
+	}
+
+}
+
+// callFunction is the trampoline that runs fn and then, one after the other,
+// the functions each of them calls in tail position, so that tail recursion
+// runs in constant Go stack. Frames of tail calls are not kept: an error is
+// only traced back to the last of them.
+func callFunction(fn *object.Function, args []object.Object) object.Object {
+	var tailCall *object.TailCall
+	calls := fn.Env.Calls()
+
+	for {
+		var result object.Object
+		if len(args) != len(fn.Parameters) {
+			result = newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+				len(args), len(fn.Parameters))
+		} else {
+			result = unwrapReturnValue(evalTail(fn.Body, extendFunctionEnv(fn, args)))
+		}
+
+		if tailCall != nil {
+			calls.Pop()
+			traceCall(result, fn, args, tailCall.Pos)
+		}
+
+		next, ok := result.(*object.TailCall)
+		if !ok {
+			return result
+		}
+		tailCall, fn, args = next, next.Function, next.Arguments
+		calls.Push(object.Call{Function: fn, Args: args, Pos: tailCall.Pos})
+	}
+}
+
+// finishTailCall performs the tail call a return statement left in result, for
+// the places that have no function to hand it to: the top level of a program,
+// and try expressions, whose catch and finally blocks must see how it ends.
+func finishTailCall(result object.Object) object.Object {
+	returnValue, ok := result.(*object.ReturnValue)
+	if !ok {
+		return result
+	}
+
+	tailCall, ok := returnValue.Value.(*object.TailCall)
+	if !ok {
+		return result
+	}
+
+	call := tracedCall(tailCall.Pos, tailCall.Function.Env.Limiter())
+	value := call(tailCall.Function, tailCall.Arguments)
+	if isError(value) {
+		return value
 	}
 
+	return &object.ReturnValue{Value: value}
 }
 
 func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
@@ -315,10 +594,16 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.EXCEPTION_OBJ:
+		return evalExceptionIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ:
+		return evalModuleIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", left.Type())
 	}
 }
 
@@ -334,50 +619,183 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+// interpolate joins the values of the parts of an interpolated string, as
+// they are inspected.
+func interpolate(parts []object.Object) *object.String {
+	var out strings.Builder
+	for _, part := range parts {
+		out.WriteString(part.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+// evalStringIndexExpression returns the character at a position counted in
+// characters rather than bytes.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 {
+		return NULL
+	}
+
+	start := charOffset(value, idx)
+	if start == len(value) {
+		return NULL
+	}
+
+	_, size := utf8.DecodeRuneInString(value[start:])
+	return &object.String{Value: value[start : start+size]}
+}
+
+// charOffset returns the offset in bytes of the character at position n in s,
+// or len(s) when s has no more than n characters. Invalid UTF-8 counts as a
+// character per byte.
+func charOffset(s string, n int64) int {
+	for offset := range s {
+		if n == 0 {
+			return offset
+		}
+		n -= 1
+	}
+
+	return len(s)
+}
+
+// evalAssignExpression evaluates the target's current value (for compound
+// operators) before the right-hand side, and the collection and index of an
+// index target exactly once.
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if operator != "" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+
+		val := evalAssignedValue(operator, current, node.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		if _, ok := env.Assign(target.Value, val); !ok {
+			return newError(object.NAME_ERROR, "cannot assign to undeclared identifier: %s", target.Value)
+		}
+		return val
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		var current object.Object
+		if operator != "" {
+			current = evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+
+		val := evalAssignedValue(operator, current, node.Value, env)
+		if isError(val) {
+			return val
+		}
+
+		result := evalIndexAssignment(left, index, val)
+		if err := env.Limiter().CheckSize(left); err != nil && !isError(result) {
+			return err
+		}
+		return result
+	default:
+		return newError(object.TYPE_ERROR, "cannot assign to %s", node.Target.String())
+	}
+}
+
+// evalAssignedValue evaluates the right-hand side of an assignment and, for a
+// compound operator, combines it with the target's current value.
+func evalAssignedValue(operator string, current object.Object, value ast.Expression, env *object.Environment) object.Object {
+	val := Eval(value, env)
+	if isError(val) || operator == "" {
+		return val
+	}
+
+	return evalInfixExpression(operator, current, val)
+}
+
+// evalIndexAssignment stores val in an array or hash in place. Arrays can only
+// be assigned within their bounds; hashes gain a new pair for unknown keys.
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(arrayObject.Elements)) {
+			return newError(object.INDEX_ERROR, "index out of range: %d", idx)
+		}
+		arrayObject.Elements[idx] = val
+		return val
+	case left.Type() == object.HASH_OBJ:
+		hashObject := left.(*object.Hash)
+		key, ok := object.AsHashable(index)
+		if !ok {
+			return newError(object.TYPE_ERROR, "%s is not usable as a hash key", index.Type())
+		}
+		hashObject.Set(key, val)
+		return val
+	default:
+		return newError(object.TYPE_ERROR, "index assignment not supported: %s", left.Type())
+	}
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash(len(node.Pairs))
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
-			return newError("%s is not usable as a hash key", key.Type())
+			return newError(object.TYPE_ERROR, "%s is not usable as a hash key", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
-		return newError("%s is not usable as a hash key", index.Type())
+		return newError(object.TYPE_ERROR, "%s is not usable as a hash key", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
-}
-
-func quote(node ast.Node) object.Object {
-	return &object.Quote{Node: node}
+	return value
 }
 
 func isTruthy(obj object.Object) bool {
@@ -401,6 +819,6 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func newError(format string, a ...any) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind string, format string, a ...any) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
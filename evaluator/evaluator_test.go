@@ -1,10 +1,13 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"github.com/benja-vq/gonkey/lexer"
 	"github.com/benja-vq/gonkey/object"
 	"github.com/benja-vq/gonkey/parser"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -38,6 +41,31 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected float64
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.5e-3", 0.0015},
+		{"0.5 + 0.25", 0.75},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"3 * 1.5", 4.5},
+		{"1 / 2.0", 0.5},
+		{"10.0 - 2 * 2", 6},
+		{"(1 + 2 + 3) / 4.0", 1.5},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Eval Float Expression Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+			testFloatObject(t, evaluated, c.expected)
+		})
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	cases := []struct {
 		input    string
@@ -62,6 +90,36 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"2.5 > 2.5", false},
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"abc" > "abd"`, false},
+		{`"b" > "abc"`, true},
+		{`"a" == "a"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{`"a" != "a"`, false},
+		{`"a" != "A"`, true},
+		{`"" < "a"`, true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, [2]] != [1, [2]]", false},
+		{"[1] == [1.0]", true},
+		{`[1] == ["1"]`, false},
+		{"[] == {}", false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{"first([]) == first([])", true},
+		{"first([]) == false", false},
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b", true},
+		{"let a = [1]; a = push(a, a); let b = [1]; b = push(b, [2]); a == b", false},
 	}
 
 	for i, c := range cases {
@@ -120,6 +178,40 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 }
 
+func TestLogicalExpressions(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"true && false", "false"},
+		{"true || false", "true"},
+		{"1 && 2", "2"},
+		{"first([]) && 2", "null"},
+		{"false && y", "false"},
+		{"0 || y", "0"},
+		{`false || "b"`, "b"},
+		{"first([]) || false", "false"},
+		{"1 < 2 && 2 < 3", "true"},
+		{"false && true || true", "true"},
+		{"true || false && false", "true"},
+		{"let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); true && f(); n", "1"},
+		{"let f = fn(n) { n == 0 || f(n - 1) }; f(100000)", "true"},
+		{"if (1 > 2 || 3 > 2) { 10 } else { 20 }", "10"},
+		{"y && true", "ERROR: 1:1: identifier not found: y"},
+		{"true && y", "ERROR: 1:9: identifier not found: y"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Logical Expression Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+
+			if evaluated.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %s want %s", evaluated.Inspect(), c.expected)
+			}
+		})
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	cases := []struct {
 		input    string
@@ -179,10 +271,38 @@ func TestErrorHandling(t *testing.T) {
 			"5 + true; 5;",
 			"type mismatch: INTEGER + BOOLEAN",
 		},
+		{
+			"fn(a) { a; }();",
+			"wrong number of arguments, got 0 want 1",
+		},
+		{
+			"fn() { 1; }(1);",
+			"wrong number of arguments, got 1 want 0",
+		},
 		{
 			"-true",
 			"unknown operator: -BOOLEAN",
 		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"let f = fn() { let m = macro(x) { x }; m(1) }; f()",
+			"macro literals are only allowed in top-level let statements",
+		},
+		{
+			"if (true) { let m = macro(x) { quote(x) }; m(1) }",
+			"macro literals are only allowed in top-level let statements",
+		},
+		{
+			"puts(macro(x) { x })",
+			"macro literals are only allowed in top-level let statements",
+		},
+		{
+			"let a = 1; a /= 0",
+			"division by zero",
+		},
 		{
 			"true + false",
 			"unknown operator: BOOLEAN + BOOLEAN",
@@ -207,6 +327,30 @@ if (10 > 1) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"foobar = 1",
+			"cannot assign to undeclared identifier: foobar",
+		},
+		{
+			"foobar += 1",
+			"identifier not found: foobar",
+		},
+		{
+			"let f = fn() { let x = 1; }; f(); x = 2;",
+			"cannot assign to undeclared identifier: x",
+		},
+		{
+			"[1, 2][2] = 3",
+			"index out of range: 2",
+		},
+		{
+			"let a = 1; a[0] = 3",
+			"index assignment not supported: INTEGER",
+		},
+		{
+			`{"a": 1}[fn(x) { x }] = 2`,
+			"FUNCTION is not usable as a hash key",
+		},
 		{
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
@@ -215,6 +359,14 @@ if (10 > 1) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"FUNCTION is not usable as a hash key",
 		},
+		{
+			`{[1, fn(x) { x }]: 1}`,
+			"ARRAY is not usable as a hash key",
+		},
+		{
+			`let a = [1]; a[0] = a; {}[a]`,
+			"ARRAY is not usable as a hash key",
+		},
 	}
 
 	for i, c := range cases {
@@ -254,6 +406,225 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a = 10; a;", 10},
+		{"let a = 5; a = a + 1;", 6},
+		{"let a = 1; let b = 2; a = b = 3; a + b;", 6},
+		{"let a = 5; a += 2; a;", 7},
+		{"let a = 5; a -= 2; a;", 3},
+		{"let a = 5; a *= 2; a;", 10},
+		{"let a = 5; a /= 2; a;", 2},
+		{"let a = 5; let f = fn() { a = 10 }; f(); a;", 10},
+		{"let a = 5; let f = fn() { let a = 1; a = 10 }; f(); a;", 5},
+		{"let f = fn(x) { x += 1; x }; f(1);", 2},
+		{"let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next();", 2},
+		{"let a = [1, 2, 3]; a[1] = 5; a[1];", 5},
+		{"let a = [1, 2, 3]; a[2] += 5; a[2];", 8},
+		{"let a = [1, 2, 3]; let b = a; b[0] = 7; a[0];", 7},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"];`, 2},
+		{`let h = {"a": 1}; h["b"] = 3; h["a"] + h["b"];`, 4},
+		{`let h = {"a": 3}; h["a"] *= 3; h["a"];`, 9},
+		{`let m = {"a": [1]}; m["a"][0] -= 2; m["a"][0];`, -1},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Assign Expression Test Case %d", i), func(t *testing.T) {
+			testIntegerObject(t, testEval(c.input), c.expected)
+		})
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected any
+	}{
+		{"let i = 0; while (i < 5) { i += 1; }; i;", 5},
+		{"let i = 0; while (i < 5) { i += 1; if (i == 3) { break; } }; i;", 3},
+		{"let i = 0; let s = 0; while (i < 5) { i += 1; if (i == 2) { continue; } s += i; }; s;", 13},
+		{"let f = fn() { while (true) { return 7; } }; f();", 7},
+		{"let i = 0; while (i < 100000) { i += 1; }; i;", 100000},
+		{"while (false) { 1 }", nil},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("While Statement Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+			if expected, ok := c.expected.(int); ok {
+				testIntegerObject(t, evaluated, int64(expected))
+			} else {
+				testNullObject(t, evaluated)
+			}
+		})
+	}
+}
+
+func TestForStatements(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"let s = 0; for (x in [1, 2, 3]) { s += x; }; s;", "6"},
+		{`let out = []; for (c in "abc") { out = push(out, c); }; out;`, "[a, b, c]"},
+		{`let out = []; for (k in {"b": 2, "a": 1}) { out = push(out, k); }; out;`, "[b, a]"},
+		{"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } s += x; }; s;", "4"},
+		{"let a = [1, 2]; for (x in a) { a = push(a, x); }; a;", "[1, 2, 1, 2]"},
+		{"let out = []; for (x in [1, 2]) { for (y in [3, 4]) { if (y == 4) { break; } out = push(out, x * y); } }; out;", "[3, 6]"},
+		{"for (x in []) { x }", "null"},
+		{"for (x in 5) { x }", "ERROR: 1:1: cannot iterate over INTEGER"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("For Statement Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+			if evaluated.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %q want %q", evaluated.Inspect(), c.expected)
+			}
+		})
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 } catch (e) { 2 }", "1"},
+		{"try { 1 + true } catch (e) { 2 }", "2"},
+		{`try { throw "oops" } catch (e) { e["message"] }`, "oops"},
+		{`try { throw "oops" } catch (e) { e["kind"] }`, "Error"},
+		{`try { throw {"kind": "ValueError", "message": "bad"} } catch (e) { [e["kind"], e["message"]] }`, "[ValueError, bad]"},
+		{`try { throw 42 } catch (e) { e["message"] }`, "42"},
+		{"try { len(1) } catch (e) { e }", "TypeError: argument to 'len' not supported, got INTEGER"},
+		{`try { {}[fn() {}] } catch (e) { e["kind"] }`, "TypeError"},
+		{"try { x } catch (e) { e[\"kind\"] }", "NameError"},
+		{"try { [1][1] = 2 } catch (e) { e[\"kind\"] }", "IndexError"},
+		{`try { throw "oops" } catch (e) { e["position"] }`, "null"},
+		{`try { throw "oops" } catch (e) { e }; e["message"]`, "oops"},
+		{"let log = []; try { 1 } finally { log = push(log, 2) }; log", "[2]"},
+		{"let log = []; try { try { 1 + true } finally { log = push(log, 2) } } catch (e) { log }", "[2]"},
+		{`let log = []; try { throw "a" } catch (e) { log = push(log, 1) } finally { log = push(log, 2) }; log`, "[1, 2]"},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", "2"},
+		{"let n = 0; let f = fn() { try { return 1 } finally { n = 5 } }; f() + n", "6"},
+		{`try { try { throw "inner" } catch (e) { throw e } } catch (e) { e["message"] }`, "inner"},
+		{`try { throw "a" } catch (e) { throw "b" }`, "ERROR: 1:31: b"},
+		{`try { 1 } finally { throw "c" }`, "ERROR: 1:21: c"},
+		{"let f = fn() { throw \"deep\" }; let g = fn() { f() }; try { g() } catch (e) { e[\"stack\"] }", "[f (1:48), g (1:61)]"},
+		{"let f = fn() { try { 1 + \"a\" } catch (e) { e[\"stack\"] } }; let g = fn() { let r = f(); r }; g()", "[f (1:84), g (1:94)]"},
+		{"let f = fn() { throw \"deep\" }; let g = fn(x) { try { f() } catch (e) { e[\"stack\"] } }; g(1)", "[f (1:55), g (1:89)]"},
+		{`throw "top"`, "ERROR: 1:1: top"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Try Expression Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+			if evaluated.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %q want %q", evaluated.Inspect(), c.expected)
+			}
+		})
+	}
+}
+
+func TestImportExpressions(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"math.mk":    "let square = fn(x) { x * x }; export let cube = fn(x) { x * square(x) }; export let two = 2;",
+		"counter.mk": "let n = 0; export let next = fn() { n += 1 };",
+		"uses.mk":    `let math = import "math"; export let eight = math.cube(math.two);`,
+		"cycle.mk":   `export let self = import "cycle";`,
+		"fails.mk":   "export let x = 1 + true;",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatalf("Could not write module: %s", err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{fmt.Sprintf(`let m = import "%s"; m.cube(3)`, path("math")), "27"},
+		{fmt.Sprintf(`(import "%s")["two"]`, path("math.mk")), "2"},
+		{fmt.Sprintf(`import "%s"`, path("math")), "module(" + path("math.mk") + ")"},
+		{fmt.Sprintf(`(import "%s").eight`, path("uses")), "8"},
+		{fmt.Sprintf(`let a = import "%s"; let b = import "%s"; [a.next(), b.next(), a == b]`,
+			path("counter"), path("counter.mk")), "[1, 2, true]"},
+		{fmt.Sprintf(`let m = import "%s"; m.square`, path("math")),
+			"ERROR: 1:" + fmt.Sprint(len(path("math"))+21) + ": square is not exported by " + path("math.mk")},
+		{fmt.Sprintf(`let m = import "%s"; m[1]`, path("math")),
+			"ERROR: 1:" + fmt.Sprint(len(path("math"))+21) + ": module key must be STRING, got INTEGER"},
+		{fmt.Sprintf(`try { import "%s" } catch (e) { e.kind }`, path("missing")), "ImportError"},
+		{fmt.Sprintf(`try { import "%s" } catch (e) { e.message }`, path("cycle")),
+			"import cycle: " + path("cycle.mk") + " -> " + path("cycle.mk")},
+		{fmt.Sprintf(`import "%s"`, path("fails")), "ERROR: " + path("fails.mk") + ":1:18: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Import Expression Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+			if evaluated.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %q want %q", evaluated.Inspect(), c.expected)
+			}
+		})
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	env := object.NewEnvironment()
+	testEvalIn(t, "let f = fn(n) { if (n > 0) { 0 + f(n - 1) } else { 0 } };", env)
+
+	cases := []struct {
+		limits   object.Limits
+		input    string
+		expected string
+	}{
+		{object.Limits{}, "f(20000)", "0"},
+		{object.Limits{MaxDepth: 5}, "f(4)", "0"},
+		{object.Limits{MaxDepth: 5}, "f(5)", "ERROR: 1:35: maximum call depth of 5 exceeded"},
+		{object.Limits{MaxSteps: 10}, "1 + 2", "3"},
+		{object.Limits{MaxSteps: 10}, "f(10)", "ERROR: 1:25: maximum of 10 evaluation steps exceeded"},
+		{object.Limits{MaxElements: 2}, "[1, 2, 3]", "ERROR: 1:1: ARRAY of size 3 exceeds the maximum of 2"},
+		{object.Limits{MaxSteps: 50}, "try { f(100) } catch (e) { 1 }", "ERROR: 1:38: maximum of 50 evaluation steps exceeded"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Eval Context Test Case %d", i), func(t *testing.T) {
+			program := parser.NewParser(lexer.NewLexer(c.input)).ParseProgram()
+
+			evaluated := EvalContext(context.Background(), program, env, c.limits)
+			if evaluated.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %q want %q", evaluated.Inspect(), c.expected)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	evaluated := EvalContext(ctx, parser.NewParser(lexer.NewLexer("1")).ParseProgram(), env, object.Limits{})
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Kind != object.LIMIT_ERROR {
+		t.Errorf("Incorrect result for a cancelled context, got %s", evaluated.Inspect())
+	}
+
+	// Eval is back to the default limits once EvalContext returns
+	testIntegerObject(t, testEvalIn(t, "f(100)", env), 0)
+	evaluated = testEvalIn(t, "f(20000)", env)
+	if evaluated.Inspect() != "ERROR: 1:35: maximum call depth of 10000 exceeded" {
+		t.Errorf("Incorrect result with the default limits, got %q", evaluated.Inspect())
+	}
+}
+
+func testEvalIn(t *testing.T, input string, env *object.Environment) object.Object {
+	t.Helper()
+
+	return Eval(parser.NewParser(lexer.NewLexer(input)).ParseProgram(), env)
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 	evaluated := testEval(input)
@@ -312,6 +683,41 @@ addTwo(2);`
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestTailCalls(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"let count = fn(n) { if (n == 0) { \"done\" } else { count(n - 1) } }; count(1000000)", "done"},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(1000000, 0)", "500000500000"},
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(1000001)", "false"},
+		{"let f = fn(n) { while (n > 0) { return f(n - 1) } n }; f(1000000)", "0"},
+		{"let f = fn(n) { if (n > 0) { return f(n - 1) } \"top\" }; return f(1000000)", "top"},
+		{"let f = fn(n) { if (n == 0) { throw \"bottom\" } f(n - 1) }; let g = fn() { try { return f(3) } catch (e) { e[\"message\"] } }; g()", "bottom"},
+		{"let f = fn(n) { if (n == 0) { throw \"bottom\" } f(n - 1) }; try { f(3) } catch (e) { e[\"stack\"] }", "[f (1:49), f (1:67)]"},
+		{"let f = fn(n) { f() }; f(1)", "ERROR: 1:18: wrong number of arguments, got 0 want 1"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Tail Call Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+			if evaluated.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %q want %q", evaluated.Inspect(), c.expected)
+			}
+		})
+	}
+
+	elements := make([]object.Object, 100000)
+	for i := range elements {
+		elements[i] = &object.Integer{Value: int64(i)}
+	}
+	env := object.NewEnvironment()
+	env.Set("numbers", &object.Array{Elements: elements})
+
+	input := "let sum = fn(i, acc) { if (i == len(numbers)) { acc } else { sum(i + 1, acc + numbers[i]) } }; sum(0, 0)"
+	testIntegerObject(t, testEvalIn(t, input, env), 4999950000)
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -377,6 +783,19 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`push([3], 5)`, []int{3, 5}},
 		{`push(1, 1)`, "argument to 'push' must be ARRAY, got INTEGER"},
 		{`push()`, "wrong number of arguments, got 0 want 2"},
+		{`len("héllo")`, 5},
+		{`len("🙈🙉🙊")`, 3},
+		{`bytes("hé")`, []int{104, 195, 169}},
+		{`bytes("")`, []int{}},
+		{`len(bytes("héllo"))`, 6},
+		{`bytes([1])`, "argument to 'bytes' must be STRING, got ARRAY"},
+		{`slice([1, 2, 3, 4], 1, 3)`, []int{2, 3}},
+		{`slice([1, 2, 3, 4], 2)`, []int{3, 4}},
+		{`slice([1, 2, 3], -1, 10)`, []int{1, 2, 3}},
+		{`slice([1, 2, 3], 2, 1)`, []int{}},
+		{`slice(1, 0)`, "argument to 'slice' must be ARRAY or STRING, got INTEGER"},
+		{`slice([1], "0")`, "argument 2 to 'slice' must be INTEGER, got STRING"},
+		{`slice([1])`, "wrong number of arguments, got 1 want 2 or 3"},
 	}
 
 	for i, c := range cases {
@@ -440,6 +859,292 @@ func TestArrayLiterals(t *testing.T) {
 
 }
 
+func TestInterpolatedStrings(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ana"; let items = [1, 2]; "Hello ${name}, you have ${len(items)} items"`, "Hello Ana, you have 2 items"},
+		{`"${1.5} ${true} ${[1, "a"]} ${{"k": 2}} ${if (false) { 1 }}"`, `1.5 true [1, a] {k: 2} null`},
+		{`let x = 2; "${x} * ${x} = ${x * x}${"!"}"`, "2 * 2 = 4!"},
+		{`"${"in ${"ner"}"}"`, "in ner"},
+		{`"\${x}"`, "${x}"},
+		{`let f = fn(n) { "n=${n}" }; f(3)`, "n=3"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Interpolated Strings Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Fatalf("Object is not a String object, got %T (%+v)", evaluated, evaluated)
+			}
+
+			if str.Value != c.expected {
+				t.Errorf("Incorrect string object value, got %q want %q", str.Value, c.expected)
+			}
+		})
+	}
+
+	evaluated := testEval(`"a ${b} c"`)
+	if errObj, ok := evaluated.(*object.Error); !ok || errObj.Message != "identifier not found: b" {
+		t.Errorf("Incorrect error, got %+v", evaluated)
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	cases := []struct {
+		input       string
+		expected    any
+		expectedErr string
+	}{
+		{`split("a,b,,c", ",")`, []string{"a", "b", "", "c"}, ""},
+		{`split("hé", "")`, []string{"h", "é"}, ""},
+		{`split("", ",")`, []string{""}, ""},
+		{`join(["a", 1, true, [2]], ", ")`, "a, 1, true, [2]", ""},
+		{`join([], "-")`, "", ""},
+		{`join(split("a b c", " "), "+")`, "a+b+c", ""},
+		{`trim("  \tmonkey \n")`, "monkey", ""},
+		{`upper("héllo")`, "HÉLLO", ""},
+		{`lower("MONKEY")`, "monkey", ""},
+		{`contains("monkey", "key")`, true, ""},
+		{`contains("monkey", "ape")`, false, ""},
+		{`starts_with("monkey", "mon")`, true, ""},
+		{`starts_with("monkey", "key")`, false, ""},
+		{`ends_with("monkey", "key")`, true, ""},
+		{`index_of("héllo", "l")`, 2, ""},
+		{`index_of("héllo", "x")`, -1, ""},
+		{`index_of("héllo", "")`, 0, ""},
+		{`replace("a-b-c", "-", "+")`, "a+b+c", ""},
+		{`repeat("ab", 3)`, "ababab", ""},
+		{`repeat("ab", 0)`, "", ""},
+		{`substr("héllo", 1, 3)`, "éll", ""},
+		{`substr("héllo", 3)`, "lo", ""},
+		{`substr("héllo", 4, 10)`, "o", ""},
+		{`substr("héllo", 10)`, "", ""},
+		{`format("%s is %d, %.2f %t %v %q", "x", 5, 1.5, true, [1], "é")`, `x is 5, 1.50 true [1] "é"`, ""},
+		{`format("100%%")`, "100%", ""},
+		{`format("%d")`, "%!d(MISSING)", ""},
+		{`split("a")`, nil, "wrong number of arguments, got 1 want 2"},
+		{`split(1, ",")`, nil, "argument 1 to 'split' must be STRING, got INTEGER"},
+		{`join("a", ",")`, nil, "argument 1 to 'join' must be ARRAY, got STRING"},
+		{`upper(["a"])`, nil, "argument 1 to 'upper' must be STRING, got ARRAY"},
+		{`contains("a", 1)`, nil, "argument 2 to 'contains' must be STRING, got INTEGER"},
+		{`replace("a", "b")`, nil, "wrong number of arguments, got 2 want 3"},
+		{`repeat("a", -1)`, nil, "argument 2 to 'repeat' must not be negative, got -1"},
+		{`repeat("ab", 9000000000)`, nil, "result of 'repeat' too large, got 9000000000 times 2 bytes"},
+		{`substr("a", "0")`, nil, "argument 2 to 'substr' must be INTEGER, got STRING"},
+		{`substr("a")`, nil, "wrong number of arguments, got 1 want 2 or 3"},
+		{`format()`, nil, "wrong number of arguments, got 0 want at least 1"},
+		{`format(1)`, nil, "argument 1 to 'format' must be STRING, got INTEGER"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("String Builtins Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+
+			if c.expectedErr != "" {
+				errObj, ok := evaluated.(*object.Error)
+				if !ok || errObj.Message != c.expectedErr {
+					t.Errorf("Incorrect error, got %+v want %q", evaluated, c.expectedErr)
+				}
+				return
+			}
+
+			switch expected := c.expected.(type) {
+			case int:
+				testIntegerObject(t, evaluated, int64(expected))
+			case bool:
+				testBooleanObject(t, evaluated, expected)
+			case string:
+				str, ok := evaluated.(*object.String)
+				if !ok || str.Value != expected {
+					t.Errorf("Incorrect string, got %+v want %q", evaluated, expected)
+				}
+			case []string:
+				array, ok := evaluated.(*object.Array)
+				if !ok || len(array.Elements) != len(expected) {
+					t.Fatalf("Incorrect array, got %+v want %q", evaluated, expected)
+				}
+				for j, el := range array.Elements {
+					if str, ok := el.(*object.String); !ok || str.Value != expected[j] {
+						t.Errorf("Incorrect element %d, got %+v want %q", j, el, expected[j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	cases := []struct {
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]", ""},
+		{`map([], fn(x) { x })`, "[]", ""},
+		{`map(["a", "b"], upper)`, "[A, B]", ""},
+		{`filter([1, 2, 3, 4], fn(x) { x > 2 })`, "[3, 4]", ""},
+		{`filter([1, 2], fn(x) { false })`, "[]", ""},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, "16", ""},
+		{`reduce([1, 2, 3], fn(acc, x) { acc * x })`, "6", ""},
+		{`reduce([], fn(acc, x) { acc + x })`, "null", ""},
+		{`let total = 0; each([1, 2, 3], fn(x) { total += x }); total`, "6", ""},
+		{`find([1, 2, 3, 4], fn(x) { x > 1 })`, "2", ""},
+		{`find([1], fn(x) { x > 1 })`, "null", ""},
+		{`any([1, 2], fn(x) { x > 1 })`, "true", ""},
+		{`any([], fn(x) { true })`, "false", ""},
+		{`all([1, 2], fn(x) { x > 1 })`, "false", ""},
+		{`all([], fn(x) { false })`, "true", ""},
+		{`sort([3, 1.5, 2])`, "[1.5, 2, 3]", ""},
+		{`sort(["b", "c", "a"])`, "[a, b, c]", ""},
+		{`sort([3, 1, 2], fn(a, b) { a > b })`, "[3, 2, 1]", ""},
+		{`sort([[2, "b"], [1, "a"], [2, "a"]], fn(a, b) { a[0] < b[0] })`, "[[1, a], [2, b], [2, a]]", ""},
+		{`let a = [2, 1]; sort(a); a`, "[2, 1]", ""},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]", ""},
+		{`concat([1], [], [2, 3])`, "[1, 2, 3]", ""},
+		{`concat()`, "[]", ""},
+		{`flatten([1, [2, [3]], []])`, "[1, 2, [3]]", ""},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]", ""},
+		{`range(4)`, "[0, 1, 2, 3]", ""},
+		{`range(2, 5)`, "[2, 3, 4]", ""},
+		{`range(0, 10, 3)`, "[0, 3, 6, 9]", ""},
+		{`range(5, 0, -2)`, "[5, 3, 1]", ""},
+		{`range(5, 0)`, "[]", ""},
+		{`unique([1, "a", 1, 2, "a", true, true])`, "[1, a, 2, true]", ""},
+		{`index_of([1, "a", 2], "a")`, "1", ""},
+		{`index_of([1, 2], 3)`, "-1", ""},
+		{`let sum = fn(xs) { reduce(xs, fn(a, b) { a + b }, 0) }; sum(map(range(1, 101), fn(x) { x * x }))`, "338350", ""},
+		{`map([1, 2], fn(x) { map([x], fn(y) { y + x }) })`, "[[2], [4]]", ""},
+		{`map(1, fn(x) { x })`, "", "argument 1 to 'map' must be ARRAY, got INTEGER"},
+		{`map([1], 1)`, "", "argument 2 to 'map' must be FUNCTION, got INTEGER"},
+		{`map([1])`, "", "wrong number of arguments, got 1 want 2"},
+		{`map([1], fn(x, y) { x })`, "", "wrong number of arguments, got 1 want 2"},
+		{`map([1, true], fn(x) { x + 1 })`, "", "type mismatch: BOOLEAN + INTEGER"},
+		{`filter([1], fn(x) { y })`, "", "identifier not found: y"},
+		{`sort([1, "a"])`, "", "type mismatch: STRING < INTEGER"},
+		{`sort([1, 2], fn(a, b) { throw "no" })`, "", "no"},
+		{`range(1, 2, 0)`, "", "argument 3 to 'range' must not be 0"},
+		{`range(0, 9000000000)`, "", "result of 'range' too large, got 9000000000 elements"},
+		{`range()`, "", "wrong number of arguments, got 0 want 1 to 3"},
+		{`concat([1], 2)`, "", "argument 2 to 'concat' must be ARRAY, got INTEGER"},
+		{`zip()`, "", "wrong number of arguments, got 0 want at least 1"},
+		{`index_of(1, 1)`, "", "argument 1 to 'index_of' must be ARRAY or STRING, got INTEGER"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Higher Order Builtins Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+
+			if c.expectedErr != "" {
+				errObj, ok := evaluated.(*object.Error)
+				if !ok || errObj.Message != c.expectedErr {
+					t.Errorf("Incorrect error, got %+v want %q", evaluated, c.expectedErr)
+				}
+				return
+			}
+
+			if evaluated.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %s want %s", evaluated.Inspect(), c.expected)
+			}
+		})
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	cases := []struct {
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b: 1, a: 2, 3: 3, true: 4}", ""},
+		{`let h = {"b": 1}; h["a"] = 2; h["b"] = 3; h`, "{b: 3, a: 2}", ""},
+		{`len({"a": 1, "b": 2})`, "2", ""},
+		{`len({})`, "0", ""},
+		{`keys({"b": 1, "a": 2})`, "[b, a]", ""},
+		{`values({"b": 1, "a": 2})`, "[1, 2]", ""},
+		{`items({"b": 1, "a": 2})`, "[[b, 1], [a, 2]]", ""},
+		{`has({"a": 1}, "a")`, "true", ""},
+		{`has({"a": 1}, "b")`, "false", ""},
+		{`has({1: first([])}, 1)`, "true", ""},
+		{`has({1: 0}, 1.0)`, "true", ""},
+		{`get({"a": 1}, "a")`, "1", ""},
+		{`get({"a": 1}, "b")`, "null", ""},
+		{`get({"a": 1}, "b", 0)`, "0", ""},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, "{a: 1, c: 3}", ""},
+		{`delete({"a": 1}, "b")`, "{a: 1}", ""},
+		{`let h = {"a": 1}; delete(h, "a"); h`, "{a: 1}", ""},
+		{`merge({"a": 1, "b": 2}, {"c": 3, "a": 4})`, "{a: 4, b: 2, c: 3}", ""},
+		{`merge()`, "{}", ""},
+		{`let h = {"a": 1}; merge(h, {"a": 2}); h`, "{a: 1}", ""},
+		{`let out = []; for (k in merge({"z": 1}, {"a": 2})) { out = push(out, k) }; out`, "[z, a]", ""},
+		{`keys([1])`, "", "argument 1 to 'keys' must be HASH, got ARRAY"},
+		{`has({}, {})`, "", "HASH is not usable as a hash key"},
+		{`get({})`, "", "wrong number of arguments, got 1 want 2 or 3"},
+		{`delete([], 1)`, "", "argument 1 to 'delete' must be HASH, got ARRAY"},
+		{`merge({}, 1)`, "", "argument 2 to 'merge' must be HASH, got INTEGER"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Hash Builtins Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+
+			if c.expectedErr != "" {
+				errObj, ok := evaluated.(*object.Error)
+				if !ok || errObj.Message != c.expectedErr {
+					t.Errorf("Incorrect error, got %+v want %q", evaluated, c.expectedErr)
+				}
+				return
+			}
+
+			if evaluated.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %s want %s", evaluated.Inspect(), c.expected)
+			}
+		})
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected any
+	}{
+		{`"héllo"[0]`, "h"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[4]`, "o"},
+		{`"héllo"[5]`, nil},
+		{`"héllo"[-1]`, nil},
+		{`let s = "🙈🙉🙊"; s[len(s) - 1]`, "🙊"},
+		{`slice("héllo", 1, 3)`, "él"},
+		{`slice("héllo", 3)`, "lo"},
+		{`slice("🙈🙉🙊", 1, 100)`, "🙉🙊"},
+		{`slice("héllo", 4, 2)`, ""},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("String Index Expressions Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+
+			expected, ok := c.expected.(string)
+			if !ok {
+				testNullObject(t, evaluated)
+				return
+			}
+
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Fatalf("Object is not a String object, got %T (%+v)", evaluated, evaluated)
+			}
+
+			if str.Value != expected {
+				t.Errorf("Incorrect string object value, got %q want %q", str.Value, expected)
+			}
+		})
+	}
+}
+
 func TestArrayIndexExpressions(t *testing.T) {
 	cases := []struct {
 		input    string
@@ -488,28 +1193,31 @@ func TestHashLiterals(t *testing.T) {
 			evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
+	if result.Len() != len(expected) {
 		t.Fatalf("Incorrect number of pairs in hash table, got %d want %d",
-			len(result.Pairs), len(expected))
+			result.Len(), len(expected))
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("No pair found for key %q", expectedKey)
+	for i, pair := range result.Pairs() {
+		if pair.Key.HashKey() != expected[i].key.HashKey() {
+			t.Errorf("Incorrect key at %d, got %s want %s",
+				i, pair.Key.Inspect(), expected[i].key.Inspect())
 			continue
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, pair.Value, expected[i].value)
 	}
 }
 
@@ -525,6 +1233,17 @@ func TestHashIndexExpressions(t *testing.T) {
 		{`{5: 5}[5]`, 5},
 		{`{true: 5}[true]`, 5},
 		{`{false: 5}[false]`, 5},
+		{`{[1, "a"]: 5}[[1, "a"]]`, 5},
+		{`{[1, "a"]: 5}[["a", 1]]`, nil},
+		{`{[[1], 2]: 5}[[[1], 2]]`, 5},
+		{`{1: 5}[1.0]`, 5},
+		{`{-0.0: 5}[0]`, 5},
+		{`{[1, 2.0]: 5}[[1.0, 2]]`, 5},
+		{`{0.5: 5}[0.5]`, 5},
+		{`{1: 4, 1.0: 5}[1]`, 5},
+		{`len({1: 4, 1.0: 5})`, 1},
+		{`let k = [1]; let h = {}; h[k] = 5; k[0] = 2; h[[1]]`, 5},
+		{`let k = [1]; let h = {}; h[k] = 5; k[0] = 2; h[k]`, nil},
 	}
 
 	for i, c := range cases {
@@ -564,6 +1283,21 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("Object is not a float object, got %T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("Incorrect object value, got %g want %g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -587,3 +1321,32 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 
 	return true
 }
+
+func TestErrorPositions(t *testing.T) {
+	cases := []struct {
+		input           string
+		expectedInspect string
+	}{
+		{"foobar", "ERROR: 1:1: identifier not found: foobar"},
+		{"let x = 5;\nx + true;", "ERROR: 2:3: type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn() {\n  -true\n};\nf();", "ERROR: 2:3: unknown operator: -BOOLEAN"},
+		{"len(1)", "ERROR: 1:4: argument to 'len' not supported, got INTEGER"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Error Position Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Fatalf("Object is not an object error, got %T (%+v)",
+					evaluated, evaluated)
+			}
+
+			if errObj.Inspect() != c.expectedInspect {
+				t.Errorf("Incorrect error, got %q want %q",
+					errObj.Inspect(), c.expectedInspect)
+			}
+		})
+	}
+}
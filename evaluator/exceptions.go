@@ -0,0 +1,91 @@
+package evaluator
+
+import (
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/object"
+)
+
+// evalTryExpression binds the exception to the catch parameter in env, the
+// way for-in loops bind their variable. Errors raised by limits can be neither
+// caught nor delayed by finally blocks.
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := finishTailCall(Eval(te.Body, env))
+	if err, ok := result.(*object.Error); ok && err.Kind == object.LIMIT_ERROR {
+		return err
+	}
+
+	if err, ok := result.(*object.Error); ok && te.Catch != nil {
+		env.Set(te.Parameter.Value, &object.Exception{Error: err, Callers: env.Calls().Frames()})
+		result = finishTailCall(Eval(te.Catch, env))
+	}
+
+	if te.Finally != nil {
+		// A finally block that does not run to its end decides the outcome
+		finally := Eval(te.Finally, env)
+		if finally != nil {
+			switch finally.Type() {
+			case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return finally
+			}
+		}
+	}
+
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+// newThrownError turns the value of a throw statement into an error. Strings
+// become the message, hashes may provide a "kind" and a "message", and
+// exceptions are raised again with the position and stack they had.
+func newThrownError(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.Exception:
+		err := *val.Error
+		err.Stack = append([]object.StackFrame(nil), val.Error.Stack...)
+		return &err
+	case *object.String:
+		return newError(object.ERROR_KIND, "%s", val.Value)
+	case *object.Hash:
+		err := newError(object.ERROR_KIND, "%s", val.Inspect())
+		if kind, ok := hashStringValue(val, "kind"); ok {
+			err.Kind = kind
+		}
+		if message, ok := hashStringValue(val, "message"); ok {
+			err.Message = message
+		}
+		return err
+	default:
+		return newError(object.ERROR_KIND, "%s", val.Inspect())
+	}
+}
+
+func hashStringValue(hash *object.Hash, key string) (string, bool) {
+	value, ok := hash.Get(&object.String{Value: key})
+	if !ok {
+		return "", false
+	}
+
+	str, ok := value.(*object.String)
+	if !ok {
+		return "", false
+	}
+
+	return str.Value, true
+}
+
+func evalExceptionIndexExpression(exception, index object.Object) object.Object {
+	key, ok := index.(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "exception key must be STRING, got %s", index.Type())
+	}
+
+	value, ok := exception.(*object.Exception).Field(key.Value)
+	if !ok {
+		return NULL
+	}
+
+	return value
+}
@@ -0,0 +1,94 @@
+package evaluator
+
+import (
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/object"
+)
+
+// Loops evaluate to null. Their variables live in the enclosing environment,
+// like the ones of let statements in an if block.
+
+func evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		if result, done := evalLoopBody(ws.Body, env); done {
+			return result
+		}
+	}
+}
+
+func evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	elements, err := iterableElements(iterable)
+	if err != nil {
+		return err
+	}
+
+	for _, el := range elements {
+		env.Set(fs.Variable.Value, el)
+
+		if result, done := evalLoopBody(fs.Body, env); done {
+			return result
+		}
+	}
+
+	return NULL
+}
+
+// evalLoopBody runs one iteration of a loop and reports whether the loop must
+// stop, along with the value the loop statement evaluates to in that case.
+// Continue simply ends the iteration.
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	result := Eval(body, env)
+	if result == nil {
+		return nil, false
+	}
+
+	switch result.Type() {
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	case object.BREAK_OBJ:
+		return NULL, true
+	default:
+		return nil, false
+	}
+}
+
+// iterableElements returns the values a for-in loop over obj visits: the
+// elements of an array, the characters of a string or the keys of a hash in
+// insertion order. Loops walk this snapshot, so their body may modify the
+// iterable.
+func iterableElements(obj object.Object) ([]object.Object, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		elements := make([]object.Object, len(obj.Elements))
+		copy(elements, obj.Elements)
+		return elements, nil
+	case *object.String:
+		elements := make([]object.Object, 0, len(obj.Value))
+		for _, char := range obj.Value {
+			elements = append(elements, &object.String{Value: string(char)})
+		}
+		return elements, nil
+	case *object.Hash:
+		elements := make([]object.Object, 0, obj.Len())
+		for _, pair := range obj.Pairs() {
+			elements = append(elements, pair.Key)
+		}
+		return elements, nil
+	default:
+		return nil, newError(object.TYPE_ERROR, "cannot iterate over %s", obj.Type())
+	}
+}
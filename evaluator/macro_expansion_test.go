@@ -0,0 +1,168 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/lexer"
+	"github.com/benja-vq/gonkey/object"
+	"github.com/benja-vq/gonkey/parser"
+	"testing"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };
+`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Incorrect amount of program statements, got %d want %d",
+			len(program.Statements), 2)
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("Object is not a Macro, got %T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Incorrect amount of macro parameters, got %d want %d",
+			len(macro.Parameters), 2)
+	}
+
+	if macro.Parameters[0].String() != "x" {
+		t.Errorf("Incorrect first macro parameter, got %s want %s",
+			macro.Parameters[0].String(), "x")
+	}
+
+	if macro.Parameters[1].String() != "y" {
+		t.Errorf("Incorrect second macro parameter, got %s want %s",
+			macro.Parameters[1].String(), "y")
+	}
+
+	expectedBody := "(x + y)"
+	if macro.Body.String() != expectedBody {
+		t.Errorf("Incorrect macro body, got %s want %s",
+			macro.Body.String(), expectedBody)
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`
+let infixExpression = macro() { quote(1 + 2); };
+
+infixExpression();
+`,
+			`(1 + 2)`,
+		},
+		{
+			`
+let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+
+reverse(2 + 2, 10 - 5);
+`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+let unless = macro(condition, consequence, alternative) {
+	quote(if (!(unquote(condition))) {
+		unquote(consequence);
+	} else {
+		unquote(alternative);
+	});
+};
+
+unless(10 > 5, puts("not greater"), puts("greater"));
+`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`
+let double = macro(a) { quote(unquote(a) * 2); };
+
+double(3); double(5);
+`,
+			`(3 * 2); (5 * 2)`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Expand Macros Test Case %d", i), func(t *testing.T) {
+			expected := testParseProgram(c.expected)
+			program := testParseProgram(c.input)
+
+			env := object.NewEnvironment()
+			DefineMacros(program, env)
+			expanded, err := ExpandMacros(program, env)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err.Inspect())
+			}
+
+			if expanded.String() != expected.String() {
+				t.Errorf("Incorrect expanded program, got %q want %q",
+					expanded.String(), expected.String())
+			}
+		})
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"let m = macro() { 1 }; m()", "1:25: macro must return a QUOTE, got INTEGER"},
+		{"let m = macro() { }; m()", "1:23: macro must return a QUOTE, got NULL"},
+		{"let m = macro(a, b) { quote(1) }; m(1)", "1:36: wrong number of arguments, got 1 want 2"},
+		{"let m = macro() { x }; m()", "1:19: identifier not found: x"},
+		{"let m = macro(a) { quote(unquote(y)) }; m(1)", "1:34: identifier not found: y"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Expand Macros Error Test Case %d", i), func(t *testing.T) {
+			program := testParseProgram(c.input)
+
+			env := object.NewEnvironment()
+			DefineMacros(program, env)
+			_, err := ExpandMacros(program, env)
+			if err == nil {
+				t.Fatalf("No error expanding %q", c.input)
+			}
+
+			if err.Pos.String()+": "+err.Message != c.expected {
+				t.Errorf("Incorrect error, got %s: %s want %s", err.Pos, err.Message, c.expected)
+			}
+		})
+	}
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	return p.ParseProgram()
+}
@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/module"
+	"github.com/benja-vq/gonkey/object"
+)
+
+// evalImportExpression evaluates the imported file in an environment of its
+// own, with macros of its own, the first time a program imports it.
+func evalImportExpression(ie *ast.ImportExpression, env *object.Environment) object.Object {
+	mod, err := module.Import(env.Modules(), ie.Path, ie.Pos().Filename, func(program *ast.Program) (map[string]object.Object, *object.Error) {
+		moduleEnv := object.NewModuleEnvironment(env)
+
+		macroEnv := object.NewEnvironment()
+		DefineMacros(program, macroEnv)
+		expanded, errObj := ExpandMacros(program, macroEnv)
+		if errObj != nil {
+			return nil, errObj
+		}
+
+		if errObj, ok := Eval(expanded, moduleEnv).(*object.Error); ok {
+			return nil, errObj
+		}
+
+		exports := make(map[string]object.Object)
+		for _, name := range module.Exports(expanded.(*ast.Program)) {
+			exports[name], _ = moduleEnv.Get(name)
+		}
+
+		return exports, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return mod
+}
+
+func evalModuleIndexExpression(mod, index object.Object) object.Object {
+	moduleObject := mod.(*object.Module)
+
+	key, ok := index.(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "module key must be STRING, got %s", index.Type())
+	}
+
+	value, ok := moduleObject.Exports[key.Value]
+	if !ok {
+		return newError(object.NAME_ERROR, "%s is not exported by %s", key.Value, moduleObject.Path)
+	}
+
+	return value
+}
@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/object"
+	"github.com/benja-vq/gonkey/token"
+)
+
+func quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+			len(call.Arguments), 1)
+	}
+
+	node, err := evalUnquoteCalls(call.Arguments[0], env)
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// evalUnquoteCalls replaces every unquote(...) call inside a quoted node with
+// the AST representation of its evaluated argument, returning the first error
+// an unquote call runs into.
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var errObj *object.Error
+
+	modified := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if !isUnquoteCall(node) || errObj != nil {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			errObj = newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+				len(call.Arguments), 1)
+			errObj.Pos = call.Pos()
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if err, ok := unquoted.(*object.Error); ok {
+			errObj = err
+			return node
+		}
+
+		converted, ok := convertObjectToASTNode(unquoted)
+		if !ok {
+			errObj = newError(object.TYPE_ERROR, "cannot unquote %s", unquoted.Type())
+			errObj.Pos = call.Pos()
+			return node
+		}
+
+		return converted
+	})
+
+	return modified, errObj
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	return callExpression.Function.TokenLiteral() == "unquote"
+}
+
+// convertObjectToASTNode returns the literal standing for obj, reporting false
+// for values no literal can stand for.
+func convertObjectToASTNode(obj object.Object) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: fmt.Sprintf("%d", obj.Value),
+		}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+	case *object.Float:
+		t := token.Token{
+			Type:    token.FLOAT,
+			Literal: obj.Inspect(),
+		}
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}, true
+	case *object.String:
+		t := token.Token{
+			Type:    token.STRING,
+			Literal: obj.Value,
+		}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.BooleanLiteral{Token: t, Value: obj.Value}, true
+	case *object.Quote:
+		return obj.Node, true
+	default:
+		return nil, false
+	}
+}
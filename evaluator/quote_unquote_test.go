@@ -44,9 +44,18 @@ func TestQuoteUnquote(t *testing.T) {
 		expected string
 	}{
 		{`quote(unquote(4))`, `4`},
-		{`quote(unquote(4 + 4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
 		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
 		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4);
+quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		{`quote(unquote("a") + "b")`, `(a + b)`},
+		{`let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)`, `(2 + 1)`},
 	}
 
 	for i, c := range cases {
@@ -69,3 +78,30 @@ func TestQuoteUnquote(t *testing.T) {
 		})
 	}
 }
+
+func TestQuoteUnquoteErrors(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`quote()`, "1:6: wrong number of arguments, got 0 want 1"},
+		{`quote(1, 2)`, "1:6: wrong number of arguments, got 2 want 1"},
+		{`quote(unquote(x))`, "1:15: identifier not found: x"},
+		{`quote(unquote())`, "1:14: wrong number of arguments, got 0 want 1"},
+		{`quote(unquote(fn() { 1 }))`, "1:14: cannot unquote FUNCTION"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Quote Unquote Error Test Case %d", i), func(t *testing.T) {
+			evaluated := testEval(c.input)
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Fatalf("Evaluated object is not an error, got %T (%+v)", evaluated, evaluated)
+			}
+
+			if errObj.Pos.String()+": "+errObj.Message != c.expected {
+				t.Errorf("Incorrect error, got %s: %s want %s", errObj.Pos, errObj.Message, c.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,692 @@
+// Package format prints Monkey programs in a canonical form: one statement per
+// line, blocks indented, operators spaced and parenthesized only where the
+// parser needs it, and array and hash literals broken over several lines when
+// they do not fit in LineWidth columns. Comments are kept, on the line of the
+// statement or element they follow or on lines of their own before the next
+// one.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/lexer"
+	"github.com/benja-vq/gonkey/parser"
+	"github.com/benja-vq/gonkey/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	LineWidth   = 80 // Columns a line should fit in
+	indentation = "    "
+)
+
+// atom is the precedence of expressions that never need parentheses.
+const atom = parser.INDEX + 1
+
+// Source formats the program src, reporting its parser errors, whose
+// positions refer to filename. Blank lines between statements are kept, a run
+// of them collapsed into one.
+func Source(filename string, src []byte) ([]byte, error) {
+	p := parser.NewParser(lexer.NewLexerWithFilename(filename, string(src)))
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	pr := &printer{src: src}
+	pr.scan()
+	pr.program(program)
+
+	return pr.out.Bytes(), nil
+}
+
+// Node returns the canonical source of a program, statement or expression,
+// which has no comments nor blank lines as it is printed without its source.
+func Node(node ast.Node) string {
+	p := &printer{}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		p.program(node)
+	case ast.Statement:
+		p.statement(node)
+		if needsSemicolon(node, nil) {
+			p.write(";")
+		}
+	case ast.Expression:
+		p.expression(node)
+	}
+
+	return p.out.String()
+}
+
+type printer struct {
+	src    []byte // Source of the printed nodes, nil when unknown
+	out    bytes.Buffer
+	indent int  // Indentation level of the current line
+	column int  // Column the next character is written at, starting at 0
+	flat   bool // Whether literals stay on one line whatever their length
+
+	comments    []comment   // Comments of src, in order
+	next        int         // Index of the first comment left to write
+	closeOffset map[int]int // Offsets of the brackets closing the ones opened at an offset
+}
+
+type comment struct {
+	text        string
+	offset      int
+	firstOnLine bool // Whether only whitespace precedes the comment on its line
+}
+
+// scan reads the comments of src and matches its brackets, which tell where
+// the blocks and literals the comments may be in end.
+func (p *printer) scan() {
+	l := lexer.NewLexer(string(p.src))
+	l.SetMode(lexer.ScanComments)
+
+	p.closeOffset = map[int]int{}
+	var open []int
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.COMMENT:
+			p.comments = append(p.comments, comment{
+				text:        strings.TrimRight(tok.Literal, " \t\r"),
+				offset:      tok.Pos.Offset,
+				firstOnLine: p.firstOnLine(tok.Pos.Offset),
+			})
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open = append(open, tok.Pos.Offset)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(open) > 0 {
+				p.closeOffset[open[len(open)-1]] = tok.Pos.Offset
+				open = open[:len(open)-1]
+			}
+		}
+	}
+}
+
+func (p *printer) firstOnLine(offset int) bool {
+	for i := offset - 1; i >= 0; i-- {
+		switch p.src[i] {
+		case '\n':
+			return true
+		case ' ', '\t', '\r':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// end returns the offset of the bracket closing the one at pos, or the end of
+// the source when unknown.
+func (p *printer) end(pos token.Position) int {
+	if offset, ok := p.closeOffset[pos.Offset]; ok && pos.IsValid() {
+		return offset
+	}
+
+	return len(p.src)
+}
+
+// hasComments reports whether there are comments between the offsets start
+// and end.
+func (p *printer) hasComments(start, end int) bool {
+	for _, c := range p.comments {
+		if c.offset > start && c.offset < end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// leadingComments writes the comments left before offset on lines of their
+// own.
+func (p *printer) leadingComments(offset int) {
+	for p.next < len(p.comments) && p.comments[p.next].offset < offset {
+		c := p.comments[p.next]
+		p.lineBreak(c.offset)
+		p.write(c.text)
+		p.next += 1
+	}
+}
+
+// trailingComments writes the comments left before offset that follow code on
+// their line at the end of the current line.
+func (p *printer) trailingComments(offset int) {
+	for p.next < len(p.comments) && p.comments[p.next].offset < offset && !p.comments[p.next].firstOnLine {
+		p.write(" " + p.comments[p.next].text)
+		p.next += 1
+	}
+}
+
+// beginLine starts the line of what is found at offset in the source, after
+// the comments preceding it.
+func (p *printer) beginLine(offset int) {
+	p.leadingComments(offset)
+	p.lineBreak(offset)
+}
+
+// lineBreak starts a new line unless nothing was written yet, leaving an empty
+// line first when there is one before offset in the source.
+func (p *printer) lineBreak(offset int) {
+	if p.out.Len() == 0 {
+		return
+	}
+
+	if p.blankLineBefore(offset) {
+		p.write("\n")
+	}
+	p.newline()
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		p.column = utf8.RuneCountInString(s[i+1:])
+	} else {
+		p.column += utf8.RuneCountInString(s)
+	}
+}
+
+func (p *printer) newline() {
+	p.write("\n" + strings.Repeat(indentation, p.indent))
+}
+
+// render returns what print writes from the current position when literals
+// are kept on one line, without writing it.
+func (p *printer) render(print func(q *printer)) string {
+	q := *p
+	q.out = bytes.Buffer{}
+	q.flat = true
+	print(&q)
+
+	return q.out.String()
+}
+
+// fits reports whether s can be written on the current line.
+func (p *printer) fits(s string) bool {
+	return !strings.Contains(s, "\n") && p.column+utf8.RuneCountInString(s) <= LineWidth
+}
+
+// blankLineBefore reports whether an empty line precedes offset in the source,
+// ignoring those that open or close a block or literal.
+func (p *printer) blankLineBefore(offset int) bool {
+	if offset >= len(p.src) || strings.IndexByte(")]}", p.src[offset]) >= 0 {
+		return false
+	}
+
+	newlines := 0
+	for i := offset - 1; i >= 0; i-- {
+		switch p.src[i] {
+		case '\n':
+			newlines += 1
+		case ' ', '\t', '\r':
+		case '(', '[', '{':
+			return false
+		default:
+			return newlines > 1
+		}
+	}
+
+	return false
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, len(p.src)+1)
+
+	if p.out.Len() > 0 {
+		p.write("\n")
+	}
+}
+
+// statements writes stmts and the comments among them on lines of their own,
+// up to the offset end in the source.
+func (p *printer) statements(stmts []ast.Statement, end int) {
+	for i, stmt := range stmts {
+		p.beginLine(offset(stmt))
+		p.statement(stmt)
+
+		var next ast.Statement
+		limit := end
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+			limit = offset(next)
+		}
+		if needsSemicolon(stmt, next) {
+			p.write(";")
+		}
+		p.trailingComments(limit)
+	}
+
+	p.leadingComments(end)
+}
+
+// stringLiteral returns the source of a string, kept raw when it was written
+// as a raw string.
+func (p *printer) stringLiteral(str *ast.StringLiteral) string {
+	if offset := offset(str); offset >= 0 && offset < len(p.src) && p.src[offset] == '`' {
+		return "`" + str.Value + "`"
+	}
+
+	return quote(str.Value)
+}
+
+// interpolatedString writes str with its embedded expressions on one line.
+func (p *printer) interpolatedString(str *ast.InterpolatedString) {
+	p.write(`"`)
+	for i, part := range str.Parts {
+		if i%2 == 0 {
+			p.write(escape(part.(*ast.StringLiteral).Value))
+			continue
+		}
+
+		p.write("${")
+		p.write(p.render(func(q *printer) { q.expression(part) }))
+		p.write("}")
+	}
+	p.write(`"`)
+}
+
+// quote returns s between double quotes, escaped.
+func quote(s string) string {
+	return `"` + escape(s) + `"`
+}
+
+// escape returns s with quotes, backslashes, control characters and the ${
+// that would start an embedded expression escaped.
+func escape(s string) string {
+	var out strings.Builder
+
+	for i, r := range s {
+		switch r {
+		case utf8.RuneError:
+			// Invalid UTF-8 is kept byte for byte
+			_, size := utf8.DecodeRuneInString(s[i:])
+			out.WriteString(s[i : i+size])
+		case '"':
+			out.WriteString(`\"`)
+		case '$':
+			if strings.HasPrefix(s[i:], "${") {
+				out.WriteByte('\\')
+			}
+			out.WriteByte('$')
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&out, `\u{%X}`, r)
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+
+	return out.String()
+}
+
+// offset returns the offset of the first token of node in the source, or -1
+// when node was not parsed from it.
+func offset(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.InfixExpression:
+		return offset(node.Left)
+	case *ast.AssignExpression:
+		return offset(node.Target)
+	case *ast.CallExpression:
+		return offset(node.Function)
+	case *ast.IndexExpression:
+		return offset(node.Left)
+	}
+
+	if pos := node.Pos(); pos.IsValid() {
+		return pos.Offset
+	}
+	return -1
+}
+
+// needsSemicolon reports whether stmt is terminated by a semicolon. Loops never
+// are, and neither are if and try expressions unless next would otherwise be
+// parsed as the rest of them.
+func needsSemicolon(stmt, next ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.WhileStatement, *ast.ForStatement:
+		return false
+	case *ast.ExpressionStatement:
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.TryExpression:
+			return continuesExpression(next)
+		}
+	}
+
+	return true
+}
+
+// continuesExpression reports whether stmt starts with a token that can also
+// follow an expression, like the opening parenthesis of a call.
+func continuesExpression(stmt ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	switch leadingChar(es.Expression) {
+	case '(', '[', '-':
+		return true
+	default:
+		return false
+	}
+}
+
+// leadingChar returns the character expr is written with first, for the
+// expressions whose first character matters to continuesExpression.
+func leadingChar(expr ast.Expression) byte {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		if precedence(expr.Left) < precedence(expr) {
+			return '('
+		}
+		return leadingChar(expr.Left)
+	case *ast.AssignExpression:
+		return leadingChar(expr.Target)
+	case *ast.CallExpression:
+		if precedence(expr.Function) < parser.CALL {
+			return '('
+		}
+		return leadingChar(expr.Function)
+	case *ast.IndexExpression:
+		if precedence(expr.Left) < parser.CALL {
+			return '('
+		}
+		return leadingChar(expr.Left)
+	case *ast.PrefixExpression:
+		return expr.Operator[0]
+	case *ast.ArrayLiteral:
+		return '['
+	default:
+		return 0
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value + " = ")
+		p.expression(stmt.Value)
+	case *ast.ExportStatement:
+		p.write("export ")
+		p.statement(stmt.Statement)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue)
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expression(stmt.Value)
+	case *ast.WhileStatement:
+		p.write("while (")
+		p.expression(stmt.Condition)
+		p.write(") ")
+		p.block(stmt.Body)
+	case *ast.ForStatement:
+		p.write("for (" + stmt.Variable.Value + " in ")
+		p.expression(stmt.Iterable)
+		p.write(") ")
+		p.block(stmt.Body)
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		p.write(stmt.TokenLiteral())
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression)
+	}
+}
+
+// block writes a block with a single statement on one line when it fits, and
+// any other block with a line for each statement. Blocks with comments always
+// span several lines.
+func (p *printer) block(block *ast.BlockStatement) {
+	start, end := block.Token.Pos.Offset, p.end(block.Token.Pos)
+
+	switch {
+	case p.hasComments(start, end):
+	case len(block.Statements) == 0:
+		p.write("{}")
+		return
+	case len(block.Statements) == 1:
+		inline := func(q *printer) {
+			q.write("{ ")
+			q.statement(block.Statements[0])
+			q.write(" }")
+		}
+
+		if p.flat {
+			inline(p)
+			return
+		}
+		if line := p.render(inline); p.fits(line) {
+			p.write(line)
+			return
+		}
+	}
+
+	p.write("{")
+	p.indent += 1
+	p.trailingComments(firstOffset(block.Statements, end))
+	p.statements(block.Statements, end)
+	p.indent -= 1
+	p.newline()
+	p.write("}")
+}
+
+func firstOffset(stmts []ast.Statement, end int) int {
+	if len(stmts) == 0 {
+		return end
+	}
+
+	return offset(stmts[0])
+}
+
+// precedence returns the precedence the parser gives to expr, which needs
+// parentheses when it is the operand of an operator binding more tightly.
+func precedence(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(expr.Token.Type)
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression:
+		return parser.INDEX
+	default:
+		return atom
+	}
+}
+
+// operand writes expr, in parentheses when its precedence is below min.
+func (p *printer) operand(expr ast.Expression, min int) {
+	if precedence(expr) < min {
+		p.write("(")
+		p.expression(expr)
+		p.write(")")
+		return
+	}
+
+	p.expression(expr)
+}
+
+func (p *printer) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		p.write(expr.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.BooleanLiteral:
+		p.write(expr.TokenLiteral())
+	case *ast.StringLiteral:
+		p.write(p.stringLiteral(expr))
+	case *ast.InterpolatedString:
+		p.interpolatedString(expr)
+	case *ast.ImportExpression:
+		p.write("import " + quote(expr.Path))
+	case *ast.PrefixExpression:
+		p.write(expr.Operator)
+		p.operand(expr.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		// Operators are left associative, an operand of the same precedence
+		// on the right needs parentheses
+		prec := precedence(expr)
+		p.operand(expr.Left, prec)
+		p.write(" " + expr.Operator + " ")
+		p.operand(expr.Right, prec+1)
+	case *ast.AssignExpression:
+		p.expression(expr.Target)
+		p.write(" " + expr.Operator + " ")
+		p.expression(expr.Value)
+	case *ast.CallExpression:
+		p.operand(expr.Function, parser.CALL)
+		p.write("(")
+		for i, arg := range expr.Arguments {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.expression(arg)
+		}
+		p.write(")")
+	case *ast.IndexExpression:
+		// Calls and indexing chain from left to right whatever their
+		// precedence
+		p.operand(expr.Left, parser.CALL)
+		if name, ok := expr.Index.(*ast.StringLiteral); ok && expr.Token.Type == token.DOT {
+			p.write("." + name.Value)
+			return
+		}
+		p.write("[")
+		p.expression(expr.Index)
+		p.write("]")
+	case *ast.ArrayLiteral:
+		p.list(expr.Token.Pos, "[", "]", expr.Elements, func(q *printer, i int) {
+			q.expression(expr.Elements[i])
+		})
+	case *ast.HashLiteral:
+		keys := hashKeys(expr)
+		p.list(expr.Token.Pos, "{", "}", keys, func(q *printer, i int) {
+			q.expression(keys[i])
+			q.write(": ")
+			q.expression(expr.Pairs[i].Value)
+		})
+	case *ast.FunctionLiteral:
+		p.write("fn")
+		p.parameters(expr.Parameters)
+		p.block(expr.Body)
+	case *ast.MacroLiteral:
+		p.write("macro")
+		p.parameters(expr.Parameters)
+		p.block(expr.Body)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(expr.Condition)
+		p.write(") ")
+		p.block(expr.Consequence)
+		if expr.Alternative != nil {
+			p.write(" else ")
+			p.block(expr.Alternative)
+		}
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(expr.Body)
+		if expr.Catch != nil {
+			p.write(" catch (" + expr.Parameter.Value + ") ")
+			p.block(expr.Catch)
+		}
+		if expr.Finally != nil {
+			p.write(" finally ")
+			p.block(expr.Finally)
+		}
+	case *ast.BlockStatement:
+		p.block(expr)
+	}
+}
+
+func (p *printer) parameters(params []*ast.Identifier) {
+	names := make([]string, 0, len(params))
+	for _, param := range params {
+		names = append(names, param.Value)
+	}
+
+	p.write("(" + strings.Join(names, ", ") + ") ")
+}
+
+// list writes the items of an array or hash literal opened at pos on one line
+// when they fit and have no comments among them, and each on a line of its
+// own otherwise. items are the elements or keys of the literal, in order.
+func (p *printer) list(pos token.Position, open, close string, items []ast.Expression, item func(q *printer, i int)) {
+	n := len(items)
+	end := p.end(pos)
+
+	inline := func(q *printer) {
+		q.write(open)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				q.write(", ")
+			}
+			item(q, i)
+		}
+		q.write(close)
+	}
+
+	comments := p.hasComments(pos.Offset, end)
+	if p.flat || n == 0 && !comments {
+		inline(p)
+		return
+	}
+	if line := p.render(inline); p.fits(line) && !comments {
+		p.write(line)
+		return
+	}
+
+	p.write(open)
+	p.indent += 1
+	for i := 0; i < n; i++ {
+		if i == 0 {
+			p.trailingComments(offset(items[0]))
+		}
+		p.beginLine(offset(items[i]))
+		item(p, i)
+		if i < n-1 {
+			p.write(",")
+			p.trailingComments(offset(items[i+1]))
+		} else {
+			p.trailingComments(end)
+		}
+	}
+	if n == 0 {
+		p.trailingComments(end)
+	}
+	p.leadingComments(end)
+	p.indent -= 1
+	p.newline()
+	p.write(close)
+}
+
+// hashKeys returns the keys of hash in the order they appear in the source.
+func hashKeys(hash *ast.HashLiteral) []ast.Expression {
+	keys := make([]ast.Expression, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		keys = append(keys, pair.Key)
+	}
+
+	return keys
+}
@@ -0,0 +1,137 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/lexer"
+	"github.com/benja-vq/gonkey/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let   x=1", "let x = 1;\n"},
+		{"1+2*3; (1+2)*3; 1-(2-3); (1-2)-3; -(1+2); -a[1]; (-a)[1]", "1 + 2 * 3;\n(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n-(1 + 2);\n-a[1];\n(-a)[1];\n"},
+		{"a == (b < c); (a == b) < c; !(!a); f(x)(y)[z]; (a + b)(c)", "a == b < c;\n(a == b) < c;\n!!a;\nf(x)(y)[z];\n(a + b)(c);\n"},
+		{"a || (b && c); (a || b) && c; (a == b) && !(c || d); x = (a || b)", "a || b && c;\n(a || b) && c;\na == b && !(c || d);\nx = a || b;\n"},
+		{"x = y = 1; x += (y = 2); (x)", "x = y = 1;\nx += y = 2;\nx;\n"},
+		{`m.name; m["name"]; m.f(1).g`, "m.name;\nm[\"name\"];\nm.f(1).g;\n"},
+		{"let f = fn(x){x*2}", "let f = fn(x) { x * 2 };\n"},
+		{"let f = fn(x, y) { let z = x + y; z * 2 }", "let f = fn(x, y) {\n    let z = x + y;\n    z * 2;\n};\n"},
+		{"fn(){}", "fn() {};\n"},
+		{"if (a) { b } else { c }\nd", "if (a) { b } else { c }\nd;\n"},
+		{"if (a) { b }; (c)(d); if (a) { b }; [1]; try { 1 } catch (e) { 2 }; -1", "if (a) { b }\nc(d);\nif (a) { b };\n[1];\ntry { 1 } catch (e) { 2 };\n-1;\n"},
+		{"while (x < 3) { x += 1 } for (y in [1, 2]) { puts(y); puts(y * 2) }", "while (x < 3) { x += 1 }\nfor (y in [1, 2]) {\n    puts(y);\n    puts(y * 2);\n}\n"},
+		{"while (true) { if (x) { break; } else { continue; } }", "while (true) { if (x) { break } else { continue } }\n"},
+		{"try { f() } finally { g() }", "try { f() } finally { g() }\n"},
+		{"let f = fn() { throw {\"kind\": \"E\", \"message\": \"m\"}; }", "let f = fn() { throw {\"kind\": \"E\", \"message\": \"m\"} };\n"},
+		{`export let m = import "lib/math";`, "export let m = import \"lib/math\";\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{"let f = fn() {\n  let a = 1;\n\n  a\n}", "let f = fn() {\n    let a = 1;\n\n    a;\n};\n"},
+		{"let unless = macro(c, a) { quote(if (!(unquote(c))) { unquote(a) }) };", "let unless = macro(c, a) { quote(if (!unquote(c)) { unquote(a) }) };\n"},
+		{"[1000000000, 2000000000, 3000000000, 4000000000, 5000000000, 6000000000, 7000000000]",
+			"[\n    1000000000,\n    2000000000,\n    3000000000,\n    4000000000,\n    5000000000,\n    6000000000,\n    7000000000\n];\n"},
+		{`let config = {"name": "gonkey", "version": 1.5, "tags": ["interpreter", "monkey", "go"], "debug": false}`,
+			"let config = {\n    \"name\": \"gonkey\",\n    \"version\": 1.5,\n    \"tags\": [\"interpreter\", \"monkey\", \"go\"],\n    \"debug\": false\n};\n"},
+		{`let h = {"f": fn(x) { let y = x; y }}`, "let h = {\n    \"f\": fn(x) {\n        let y = x;\n        y;\n    }\n};\n"},
+		{"// header\n\nlet x=1; // one\n  // lead\nlet y=2;", "// header\n\nlet x = 1; // one\n// lead\nlet y = 2;\n"},
+		{"let x = 1;\n\n\n/* alone */\n\nlet y = 2; /* last */\n// end", "let x = 1;\n\n/* alone */\n\nlet y = 2; /* last */\n// end\n"},
+		{"let f = fn() { // open\n  a; // a\n\n  // end\n}", "let f = fn() { // open\n    a; // a\n\n    // end\n};\n"},
+		{"let f = fn() { a } // f", "let f = fn() { a }; // f\n"},
+		{"if (a) { /* b */ }", "if (a) { /* b */\n}\n"},
+		{"let a = [1, // one\n 2 /* two */]", "let a = [\n    1, // one\n    2 /* two */\n];\n"},
+		{"let h = {\n  // a\n  \"a\": 1,\n  \"b\": 2 // b\n}", "let h = {\n    // a\n    \"a\": 1,\n    \"b\": 2 // b\n};\n"},
+		{"/* a /* nested */ comment */", "/* a /* nested */ comment */\n"},
+		{`"a\tb" + "q\"\\" + "\u{48}\u{7}é"`, `"a\tb" + "q\"\\" + "H\u{7}é";` + "\n"},
+		{"let s = `raw\n\\ \"line\"`", "let s = `raw\n\\ \"line\"`;\n"},
+		{`"Hi ${  name }, ${len( [1,2] )+1} \${no} $${x}${"${y}"}"`, `"Hi ${name}, ${len([1, 2]) + 1} \${no} $${x}${"${y}"}";` + "\n"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Source Test Case %d", i), func(t *testing.T) {
+			formatted, err := Source("", []byte(c.input))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if string(formatted) != c.expected {
+				t.Errorf("Incorrect formatting, got %q want %q", formatted, c.expected)
+			}
+
+			testRoundTrip(t, c.input, string(formatted))
+		})
+	}
+}
+
+// testRoundTrip checks that formatted parses to the same tree as input, and
+// that formatting it again changes nothing.
+func testRoundTrip(t *testing.T, input, formatted string) {
+	t.Helper()
+
+	if dump(t, formatted) != dump(t, input) {
+		t.Errorf("Formatted program parses to a different tree:\n%s\nwant\n%s", dump(t, formatted), dump(t, input))
+	}
+
+	again, err := Source("", []byte(formatted))
+	if err != nil {
+		t.Fatalf("Unexpected error formatting again: %s", err)
+	}
+	if string(again) != formatted {
+		t.Errorf("Formatting is not idempotent, got %q want %q", again, formatted)
+	}
+}
+
+func dump(t *testing.T, input string) string {
+	t.Helper()
+
+	p := parser.NewParser(lexer.NewLexer(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("Could not parse %q: %s", input, strings.Join(p.Errors(), "; "))
+	}
+
+	var out bytes.Buffer
+	ast.Dump(&out, program)
+
+	return positions.ReplaceAllString(out.String(), "")
+}
+
+// positions matches the positions ending the lines of ast.Dump, which differ
+// between a program and its formatted source.
+var positions = regexp.MustCompile(`(?m) @ \S+$`)
+
+func TestSourceErrors(t *testing.T) {
+	_, err := Source("script.mk", []byte("let x = ;"))
+	if err == nil || err.Error() != "script.mk:1:9: No prefix parse function found for ;" {
+		t.Errorf("Incorrect error, got %v", err)
+	}
+}
+
+func TestNode(t *testing.T) {
+	program := parser.NewParser(lexer.NewLexer("let x = (1 + 2) * 3; if (x) { y }")).ParseProgram()
+
+	cases := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{program, "let x = (1 + 2) * 3;\nif (x) { y }\n"},
+		{program.Statements[0], "let x = (1 + 2) * 3;"},
+		{program.Statements[0].(*ast.LetStatement).Value, "(1 + 2) * 3"},
+		{program.Statements[1], "if (x) { y }"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Node Test Case %d", i), func(t *testing.T) {
+			if formatted := Node(c.node); formatted != c.expected {
+				t.Errorf("Incorrect formatting, got %q want %q", formatted, c.expected)
+			}
+		})
+	}
+}
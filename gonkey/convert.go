@@ -0,0 +1,515 @@
+package gonkey
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/evaluator"
+	"github.com/benja-vq/gonkey/object"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	anyType    = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+// ToObject converts a Go value to a Monkey value:
+//
+//   - nil and nil pointers become null, other pointers the value they point to
+//   - booleans, integers, floats and strings become their Monkey counterpart
+//   - slices and arrays become arrays, maps become hashes
+//   - structs become hashes keyed by field name, or by the name given in a
+//     `gonkey:"name"` tag, fields tagged `gonkey:"-"` and unexported fields
+//     being left out
+//   - functions become builtins converting their arguments with FromObject's
+//     rules and their result with ToObject. A function may return nothing,
+//     one value, an error, or a value and an error, a non-nil error being
+//     raised as a Monkey error
+//
+// Values that already are Monkey values are returned as they are.
+func ToObject(v any) (object.Object, error) {
+	if obj, ok := v.(object.Object); ok {
+		return obj, nil
+	}
+
+	return toObject(reflect.ValueOf(v), nil)
+}
+
+// visit is a pointer, slice or map being converted.
+type visit struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// toObject converts v like ToObject, outer holding the pointers, slices and
+// maps v is reached through, so that values containing themselves are
+// reported instead of converted forever.
+func toObject(v reflect.Value, outer []visit) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if v.Kind() == reflect.Pointer {
+			var err error
+			if outer, err = enter(v, outer); err != nil {
+				return nil, err
+			}
+		}
+		return toObject(v.Elem(), outer)
+	case reflect.Bool:
+		return evaluator.NativeBoolToBooleanObject(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("gonkey: %d overflows a Monkey integer", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if v.Kind() == reflect.Slice {
+			var err error
+			if outer, err = enter(v, outer); err != nil {
+				return nil, err
+			}
+		}
+
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := toObject(v.Index(i), outer)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		var err error
+		if outer, err = enter(v, outer); err != nil {
+			return nil, err
+		}
+
+		// Go maps are unordered, sort their keys for hashes to list them the
+		// same way every time
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+
+		hash := object.NewHash(len(keys))
+		for _, key := range keys {
+			if err := setPair(hash, key, v.MapIndex(key), outer); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := object.NewHash(v.NumField())
+		for _, field := range reflect.VisibleFields(v.Type()) {
+			name, ok := fieldName(field)
+			if !ok {
+				continue
+			}
+			// Fields promoted through a nil embedded pointer have no value
+			value, err := v.FieldByIndexErr(field.Index)
+			if err != nil {
+				continue
+			}
+			if err := setPair(hash, reflect.ValueOf(name), value, outer); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return wrapFunction("function", v.Interface())
+	default:
+		return nil, fmt.Errorf("gonkey: cannot convert %s to a Monkey value", v.Type())
+	}
+}
+
+// enter adds v, a pointer, slice or map, to outer, returning an error when v
+// is already being converted.
+func enter(v reflect.Value, outer []visit) ([]visit, error) {
+	current := visit{typ: v.Type(), ptr: v.Pointer()}
+	if v.Kind() == reflect.Slice {
+		current.len = v.Len()
+	}
+
+	if slices.Contains(outer, current) {
+		return nil, fmt.Errorf("gonkey: cannot convert %s containing itself", v.Type())
+	}
+
+	return append(outer, current), nil
+}
+
+func setPair(hash *object.Hash, k, v reflect.Value, outer []visit) error {
+	key, err := toObject(k, outer)
+	if err != nil {
+		return err
+	}
+
+	hashable, ok := object.AsHashable(key)
+	if !ok {
+		return fmt.Errorf("gonkey: %s is not usable as a hash key", key.Type())
+	}
+
+	value, err := toObject(v, outer)
+	if err != nil {
+		return err
+	}
+
+	hash.Set(hashable, value)
+	return nil
+}
+
+// lessKey orders Go map keys, numbers by value and others by their formatting.
+func lessKey(a, b reflect.Value) bool {
+	switch {
+	case a.CanInt() && b.CanInt():
+		return a.Int() < b.Int()
+	case a.CanUint() && b.CanUint():
+		return a.Uint() < b.Uint()
+	case a.CanFloat() && b.CanFloat():
+		return a.Float() < b.Float()
+	default:
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	}
+}
+
+// fieldName returns the hash key of a struct field, reporting false for the
+// fields left out of hashes.
+func fieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() || field.Anonymous {
+		return "", false
+	}
+
+	switch tag := field.Tag.Get("gonkey"); tag {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return tag, true
+	}
+}
+
+// FromObject converts a Monkey value to a Go value: null becomes nil,
+// integers int64, floats float64, strings string, booleans bool, arrays []any
+// and hashes map[any]any, their array keys becoming [n]any arrays. Arrays and
+// hashes containing themselves become slices and maps containing themselves.
+// Other values, such as functions, are returned as they are.
+func FromObject(obj object.Object) any {
+	return fromValue(obj, nil)
+}
+
+// fromValue converts obj like FromObject, converted holding the Go values of
+// the arrays and hashes already converted, which obj may contain again.
+func fromValue(obj object.Object, converted map[object.Object]any) any {
+	if value, ok := converted[obj]; ok {
+		return value
+	}
+
+	switch obj := obj.(type) {
+	case *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Array:
+		if converted == nil {
+			converted = make(map[object.Object]any)
+		}
+		elements := make([]any, len(obj.Elements))
+		converted[obj] = elements
+		for i, element := range obj.Elements {
+			elements[i] = fromValue(element, converted)
+		}
+		return elements
+	case *object.Hash:
+		if converted == nil {
+			converted = make(map[object.Object]any)
+		}
+		pairs := make(map[any]any, obj.Len())
+		converted[obj] = pairs
+		for _, pair := range obj.Pairs() {
+			pairs[fromKey(pair.Key)] = fromValue(pair.Value, converted)
+		}
+		return pairs
+	default:
+		return obj
+	}
+}
+
+// fromKey converts a hash key to a Go map key, arrays, which slices cannot
+// be, becoming Go arrays.
+func fromKey(key object.Hashable) any {
+	arr, ok := key.(*object.Array)
+	if !ok {
+		return FromObject(key)
+	}
+
+	v := reflect.New(reflect.ArrayOf(len(arr.Elements), anyType)).Elem()
+	for i, element := range arr.Elements {
+		v.Index(i).Set(reflect.ValueOf(fromKey(element.(object.Hashable))))
+	}
+	return v.Interface()
+}
+
+// fromMapKey converts key to a Go map key of type t, following the rules of
+// fromKey for interface types.
+func fromMapKey(key object.Hashable, t reflect.Type) (reflect.Value, error) {
+	if t.Kind() != reflect.Interface {
+		return fromObject(key, t)
+	}
+
+	v := reflect.ValueOf(fromKey(key))
+	if !v.Type().AssignableTo(t) {
+		return v, fmt.Errorf("cannot convert %s to %s", key.Type(), t)
+	}
+
+	return v, nil
+}
+
+// fromObject converts obj to a Go value of type t, following the rules of
+// FromObject for the empty interface.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		if value := FromObject(obj); value != nil {
+			v.Set(reflect.ValueOf(value))
+		}
+		return v, nil
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+
+	switch obj := obj.(type) {
+	case *object.Null:
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			return v, nil
+		}
+	case *object.Integer:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !v.OverflowInt(obj.Value) {
+				v.SetInt(obj.Value)
+				return v, nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if obj.Value >= 0 && !v.OverflowUint(uint64(obj.Value)) {
+				v.SetUint(uint64(obj.Value))
+				return v, nil
+			}
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(obj.Value))
+			return v, nil
+		}
+	case *object.Float:
+		switch t.Kind() {
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(obj.Value)
+			return v, nil
+		}
+	case *object.String:
+		if t.Kind() == reflect.String {
+			v.SetString(obj.Value)
+			return v, nil
+		}
+	case *object.Boolean:
+		if t.Kind() == reflect.Bool {
+			v.SetBool(obj.Value)
+			return v, nil
+		}
+	case *object.Array:
+		switch t.Kind() {
+		case reflect.Slice:
+			v.Set(reflect.MakeSlice(t, len(obj.Elements), len(obj.Elements)))
+			return v, setElements(v, obj.Elements)
+		case reflect.Array:
+			if t.Len() == len(obj.Elements) {
+				return v, setElements(v, obj.Elements)
+			}
+		}
+	case *object.Hash:
+		switch t.Kind() {
+		case reflect.Map:
+			v.Set(reflect.MakeMapWithSize(t, obj.Len()))
+			for _, pair := range obj.Pairs() {
+				key, err := fromMapKey(pair.Key, t.Key())
+				if err != nil {
+					return v, err
+				}
+				value, err := fromObject(pair.Value, t.Elem())
+				if err != nil {
+					return v, err
+				}
+				v.SetMapIndex(key, value)
+			}
+			return v, nil
+		case reflect.Struct:
+			return v, setFields(v, obj)
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, err := fromObject(obj, t.Elem())
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(elem)
+		return v, nil
+	}
+
+	return v, fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+}
+
+func setElements(v reflect.Value, elements []object.Object) error {
+	for i, element := range elements {
+		value, err := fromObject(element, v.Type().Elem())
+		if err != nil {
+			return err
+		}
+		v.Index(i).Set(value)
+	}
+
+	return nil
+}
+
+// setFields sets the fields of the struct v to the values of the hash keys
+// named after them, leaving the fields without a key to their zero value.
+func setFields(v reflect.Value, hash *object.Hash) error {
+	for _, field := range reflect.VisibleFields(v.Type()) {
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+
+		obj, ok := hash.Get(&object.String{Value: name})
+		if !ok {
+			continue
+		}
+
+		target, err := v.FieldByIndexErr(field.Index)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+		value, err := fromObject(obj, field.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+		target.Set(value)
+	}
+
+	return nil
+}
+
+// wrapFunction returns a builtin calling the Go function fn, name being used
+// in the errors raised when the arguments cannot be converted.
+func wrapFunction(name string, fn any) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("gonkey: %s is not a function, got %T", name, fn)
+	}
+
+	t := v.Type()
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("gonkey: %s must return at most a value and an error, got %s", name, t)
+	}
+
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		numIn := t.NumIn()
+		switch {
+		case t.IsVariadic() && len(args) < numIn-1:
+			return &object.Error{
+				Kind:    object.ARGUMENT_ERROR,
+				Message: fmt.Sprintf("wrong number of arguments, got %d want at least %d", len(args), numIn-1),
+			}
+		case !t.IsVariadic() && len(args) != numIn:
+			return &object.Error{
+				Kind:    object.ARGUMENT_ERROR,
+				Message: fmt.Sprintf("wrong number of arguments, got %d want %d", len(args), numIn),
+			}
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= numIn-1 {
+				paramType = t.In(numIn - 1).Elem()
+			} else {
+				paramType = t.In(i)
+			}
+
+			value, err := fromObject(arg, paramType)
+			if err != nil {
+				return &object.Error{
+					Kind:    object.TYPE_ERROR,
+					Message: fmt.Sprintf("argument %d to '%s' not supported: %s", i+1, name, err),
+				}
+			}
+			in[i] = value
+		}
+
+		return goResult(v.Call(in))
+	}}, nil
+}
+
+// goResult converts the values returned by a Go function to a Monkey value.
+func goResult(out []reflect.Value) object.Object {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return &object.Error{Kind: object.ERROR_KIND, Message: err.Error()}
+		}
+		out = out[:len(out)-1]
+	}
+
+	if len(out) == 0 {
+		return evaluator.NULL
+	}
+
+	obj, err := toObject(out[0], nil)
+	if err != nil {
+		return &object.Error{Kind: object.TYPE_ERROR, Message: err.Error()}
+	}
+
+	return obj
+}
@@ -0,0 +1,192 @@
+package gonkey
+
+import (
+	"context"
+	"fmt"
+	"github.com/benja-vq/gonkey/object"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type point struct {
+	X, Y   int
+	Label  string `gonkey:"label"`
+	Hidden bool   `gonkey:"-"`
+	secret int
+}
+
+type node struct {
+	Next *node
+}
+
+type labelled struct {
+	*point
+	Name string
+}
+
+func TestToObject(t *testing.T) {
+	var nilPointer *point
+	shared := &node{}
+	var nilObject object.Object
+
+	cases := []struct {
+		value    any
+		expected string
+	}{
+		{nil, "null"},
+		{nilPointer, "null"},
+		{[]object.Object{nilObject}, "[null]"},
+		{true, "true"},
+		{int8(-3), "-3"},
+		{uint16(7), "7"},
+		{uint64(math.MaxInt64), "9223372036854775807"},
+		{float32(0.5), "0.5"},
+		{"monkey", "monkey"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{[]any{1, "a", nil, []string{"b"}}, "[1, a, null, [b]]"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{map[int]string{10: "b", 9: "a"}, "{9: a, 10: b}"},
+		{map[[2]int]string{{1, 2}: "a"}, "{[1, 2]: a}"},
+		{point{X: 1, Label: "p", Hidden: true, secret: 2}, "{X: 1, Y: 0, label: p}"},
+		{&point{Y: 2}, "{X: 0, Y: 2, label: }"},
+		{&object.Integer{Value: 5}, "5"},
+		{labelled{Name: "a"}, "{Name: a}"},
+		{labelled{point: &point{X: 1}, Name: "b"}, "{X: 1, Y: 0, label: , Name: b}"},
+		{[]*node{shared, shared}, "[{Next: null}, {Next: null}]"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("To Object Test Case %d", i), func(t *testing.T) {
+			obj, err := ToObject(c.value)
+			if err != nil {
+				t.Fatalf("Could not convert %#v: %s", c.value, err)
+			}
+
+			if obj.Inspect() != c.expected {
+				t.Errorf("Incorrect object, got %q want %q", obj.Inspect(), c.expected)
+			}
+		})
+	}
+
+	cyclicNode := &node{}
+	cyclicNode.Next = cyclicNode
+	cyclicSlice := []any{nil}
+	cyclicSlice[0] = cyclicSlice
+	cyclicMap := map[string]any{}
+	cyclicMap["m"] = cyclicMap
+
+	for _, value := range []any{
+		make(chan int), map[struct{ A int }]int{{1}: 1}, complex(1, 2), uint64(math.MaxUint64),
+		cyclicNode, cyclicSlice, cyclicMap,
+	} {
+		if _, err := ToObject(value); err == nil {
+			t.Errorf("No error converting %#v", value)
+		}
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	hash, _ := ToObject(map[string]any{"a": []int{1}, "b": nil})
+	arrayKeys, _ := ToObject(map[[2]any]int{{1, "a"}: 2})
+
+	cases := []struct {
+		obj      object.Object
+		expected any
+	}{
+		{&object.Null{}, nil},
+		{&object.Integer{Value: 3}, int64(3)},
+		{&object.Float{Value: 1.5}, 1.5},
+		{&object.String{Value: "s"}, "s"},
+		{&object.Boolean{Value: true}, true},
+		{&object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.String{Value: "a"}}},
+			[]any{int64(1), "a"}},
+		{hash, map[any]any{"a": []any{int64(1)}, "b": nil}},
+		{arrayKeys, map[any]any{[2]any{int64(1), "a"}: int64(2)}},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("From Object Test Case %d", i), func(t *testing.T) {
+			value := FromObject(c.obj)
+			if !reflect.DeepEqual(value, c.expected) {
+				t.Errorf("Incorrect value, got %#v want %#v", value, c.expected)
+			}
+		})
+	}
+
+	cyclic := &object.Array{Elements: []object.Object{&object.Integer{Value: 0}}}
+	cyclic.Elements[0] = cyclic
+	value, ok := FromObject(cyclic).([]any)
+	if !ok || len(value) != 1 || reflect.ValueOf(value[0]).Pointer() != reflect.ValueOf(value).Pointer() {
+		t.Errorf("Incorrect value for an array containing itself, got %T", value)
+	}
+}
+
+func TestFromObjectToType(t *testing.T) {
+	in := New()
+
+	var got struct {
+		P     point
+		Ptr   *point
+		Bytes []uint8
+		Pair  [2]float64
+		Any   any
+		Map   map[string][]int
+	}
+	if err := in.SetGlobal("none", nil); err != nil {
+		t.Fatalf("Could not set global: %s", err)
+	}
+	if err := in.Register("capture", func(p point, ptr *point, bytes []uint8, pair [2]float64, a any, m map[string][]int) {
+		got.P, got.Ptr, got.Bytes, got.Pair, got.Any, got.Map = p, ptr, bytes, pair, a, m
+	}); err != nil {
+		t.Fatalf("Could not register function: %s", err)
+	}
+
+	_, err := in.Run(context.Background(), `capture({"X": 1, "label": "a", "Hidden": true}, none, [1, 255], [1, 2.5], [true], {"k": [1]})`)
+	if err != nil {
+		t.Fatalf("Could not run program: %s", err)
+	}
+
+	if got.P != (point{X: 1, Label: "a"}) || got.Ptr != nil || !reflect.DeepEqual(got.Bytes, []uint8{1, 255}) ||
+		got.Pair != [2]float64{1, 2.5} || !reflect.DeepEqual(got.Any, []any{true}) ||
+		!reflect.DeepEqual(got.Map, map[string][]int{"k": {1}}) {
+		t.Errorf("Incorrect arguments, got %+v", got)
+	}
+
+	var anyKeys map[any]any
+	if err := in.Register("capture_any", func(m map[any]any) { anyKeys = m }); err != nil {
+		t.Fatalf("Could not register function: %s", err)
+	}
+	if _, err := in.Run(context.Background(), `capture_any({[1, "a"]: 2, "b": 3})`); err != nil {
+		t.Fatalf("Could not run program: %s", err)
+	}
+	if !reflect.DeepEqual(anyKeys, map[any]any{[2]any{int64(1), "a"}: int64(2), "b": int64(3)}) {
+		t.Errorf("Incorrect map with interface keys, got %#v", anyKeys)
+	}
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`capture({"X": "1"}, none, [], [1, 2], 1, {})`, "argument 1 to 'capture' not supported: field X: cannot convert STRING to int"},
+		{`capture({}, none, [256], [1, 2], 1, {})`, "argument 3 to 'capture' not supported: cannot convert INTEGER to uint8"},
+		{`capture({}, none, [], [1], 1, {})`, "argument 4 to 'capture' not supported: cannot convert ARRAY to [2]float64"},
+		{`capture({}, 1, [], [1, 2], 1, {})`, "argument 2 to 'capture' not supported: cannot convert INTEGER to gonkey.point"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("From Object To Type Test Case %d", i), func(t *testing.T) {
+			_, err := in.Run(context.Background(), c.input)
+
+			runtimeErr, ok := err.(*RuntimeError)
+			if !ok {
+				t.Fatalf("Error is not a runtime error, got %v", err)
+			}
+
+			if runtimeErr.Err.Message != c.expected {
+				t.Errorf("Incorrect error, got %q want %q", runtimeErr.Err.Message, c.expected)
+			}
+		})
+	}
+}
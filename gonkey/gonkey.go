@@ -0,0 +1,204 @@
+// Package gonkey embeds the Monkey interpreter in Go programs.
+//
+// An Interpreter keeps its global bindings between runs, so a host can load a
+// program once and call its functions many times:
+//
+//	in := gonkey.New(gonkey.WithOutput(&log))
+//	if err := in.Register("lookup", lookup); err != nil { ... }
+//	if _, err := in.Run(ctx, rules); err != nil { ... }
+//	result, err := in.Call("allow", request)
+//
+// Go values are converted to Monkey values with ToObject and back with
+// FromObject, see their documentation for the supported types.
+package gonkey
+
+import (
+	"context"
+	"fmt"
+	"github.com/benja-vq/gonkey/evaluator"
+	"github.com/benja-vq/gonkey/lexer"
+	"github.com/benja-vq/gonkey/object"
+	"github.com/benja-vq/gonkey/parser"
+	"io"
+	"strings"
+)
+
+// Interpreter runs Monkey programs on the tree-walking evaluator. Its globals,
+// macros and builtins are its own, several interpreters can be used side by
+// side. An Interpreter is not safe for concurrent use.
+type Interpreter struct {
+	filename string
+	limits   object.Limits
+	env      *object.Environment
+	macroEnv *object.Environment
+}
+
+type Option func(*Interpreter)
+
+// WithFilename sets the file name reported in the positions of errors. Imports
+// are resolved relative to it when it names a file.
+func WithFilename(filename string) Option {
+	return func(in *Interpreter) {
+		in.filename = filename
+	}
+}
+
+// WithLimits bounds the resources used by Run and Call, the default being
+// object.DefaultLimits. A program going over them raises a LimitError, which
+// try expressions do not catch.
+func WithLimits(limits object.Limits) Option {
+	return func(in *Interpreter) {
+		in.limits = limits
+	}
+}
+
+// WithOutput makes puts write to w instead of the standard output.
+func WithOutput(w io.Writer) Option {
+	return WithBuiltin("puts", func(args ...object.Object) object.Object {
+		for _, arg := range args {
+			_, _ = fmt.Fprintln(w, arg.Inspect())
+		}
+
+		return evaluator.NULL
+	})
+}
+
+// WithBuiltin defines a builtin taking and returning Monkey values, hiding the
+// builtin of the same name if there is one. See Register for plain Go
+// functions.
+func WithBuiltin(name string, fn object.BuiltinFunction) Option {
+	return func(in *Interpreter) {
+		in.env.SetBuiltin(name, &object.Builtin{Fn: fn})
+	}
+}
+
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{
+		limits:   object.DefaultLimits,
+		env:      object.NewEnvironment(),
+		macroEnv: object.NewEnvironment(),
+	}
+
+	for _, opt := range opts {
+		opt(in)
+	}
+
+	return in
+}
+
+// Run evaluates src and returns the value of its last statement. Parser errors
+// are reported as a *ParseError and runtime errors, including the ones raised
+// when ctx is done, as a *RuntimeError.
+func (in *Interpreter) Run(ctx context.Context, src string) (object.Object, error) {
+	l := lexer.NewLexerWithFilename(in.filename, src)
+	p := parser.NewParser(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Messages: p.Errors()}
+	}
+
+	evaluator.DefineMacros(program, in.macroEnv)
+	expanded, errObj := evaluator.ExpandMacros(program, in.macroEnv)
+	if errObj != nil {
+		return nil, &RuntimeError{Err: errObj}
+	}
+
+	return result(evaluator.EvalContext(ctx, expanded, in.env, in.limits))
+}
+
+// Call calls the global function or builtin called name, converting args with
+// ToObject.
+func (in *Interpreter) Call(name string, args ...any) (object.Object, error) {
+	fn, ok := in.env.Get(name)
+	if !ok {
+		fn, ok = in.env.Builtin(name)
+	}
+	if !ok {
+		if fn, ok = evaluator.GetBuiltin(name); !ok {
+			return nil, fmt.Errorf("gonkey: %s is not defined", name)
+		}
+	}
+
+	switch fn.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return nil, fmt.Errorf("gonkey: %s is not a function, got %s", name, fn.Type())
+	}
+
+	objects := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := ToObject(arg)
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = obj
+	}
+
+	stop := in.env.Limiter().Start(context.Background(), in.limits)
+	defer stop()
+
+	return result(evaluator.ApplyFunction(fn, objects, in.env.Limiter()))
+}
+
+// Register defines a builtin calling fn, a Go function, see ToObject for the
+// way functions are converted.
+func (in *Interpreter) Register(name string, fn any) error {
+	builtin, err := wrapFunction(name, fn)
+	if err != nil {
+		return err
+	}
+
+	in.env.SetBuiltin(name, builtin)
+	return nil
+}
+
+// SetGlobal binds name to value, converted with ToObject.
+func (in *Interpreter) SetGlobal(name string, value any) error {
+	obj, err := ToObject(value)
+	if err != nil {
+		return err
+	}
+
+	in.env.Set(name, obj)
+	return nil
+}
+
+// GetGlobal returns the value bound to name by a program or SetGlobal.
+func (in *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	return in.env.Get(name)
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: errObj}
+	}
+
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+
+	return obj, nil
+}
+
+// ParseError reports the syntax errors of a program.
+type ParseError struct {
+	Messages []string
+}
+
+func (e *ParseError) Error() string {
+	return strings.Join(e.Messages, "\n")
+}
+
+// RuntimeError reports the Monkey error a program raised without catching it.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Err.Pos.IsValid() {
+		return e.Err.Pos.String() + ": " + e.Err.Message
+	}
+
+	return e.Err.Message
+}
@@ -0,0 +1,325 @@
+package gonkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/benja-vq/gonkey/object"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{"let x = 5; x * 2", "10", ""},
+		{"let x = 5;", "null", ""},
+		{"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(false, 1, 2)", "1", ""},
+		{"1 + true", "", "rules.mk:1:3: type mismatch: INTEGER + BOOLEAN"},
+		{"let 5", "", "rules.mk:1:5: Peeking returned an incorrect token, got INT want IDENT"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Run Test Case %d", i), func(t *testing.T) {
+			in := New(WithFilename("rules.mk"))
+			result, err := in.Run(context.Background(), c.input)
+
+			if c.expectedErr != "" {
+				if err == nil || err.Error() != c.expectedErr {
+					t.Fatalf("Incorrect error, got %v want %q", err, c.expectedErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if result.Inspect() != c.expected {
+				t.Errorf("Incorrect result, got %q want %q", result.Inspect(), c.expected)
+			}
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	in := New()
+
+	_, err := in.Run(context.Background(), "let f = fn(x) { x / y }; f(1)")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("Error is not a runtime error, got %T", err)
+	}
+
+	if runtimeErr.Err.Kind != object.NAME_ERROR || len(runtimeErr.Err.Stack) != 1 {
+		t.Errorf("Incorrect runtime error, got %+v", runtimeErr.Err)
+	}
+
+	_, err = in.Run(context.Background(), "1 / 0")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.RUNTIME_ERROR {
+		t.Errorf("Incorrect error dividing by zero, got %v", err)
+	}
+
+	_, err = in.Run(context.Background(), "let = 1; let 2")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("Error is not a parse error, got %T", err)
+	}
+
+	if len(parseErr.Messages) != 3 {
+		t.Errorf("Incorrect amount of parse errors, got %d want %d", len(parseErr.Messages), 3)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = in.Run(ctx, "1")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Err.Kind != object.LIMIT_ERROR {
+		t.Errorf("Incorrect error for a cancelled context, got %v", err)
+	}
+
+	if _, err := in.Run(context.Background(), "1"); err != nil {
+		t.Errorf("Cancelled context outlived its run: %s", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	cases := []struct {
+		limits   object.Limits
+		input    string
+		expected string
+	}{
+		{object.DefaultLimits, "let f = fn() { 1 + f() }; f()", "1:21: maximum call depth of 10000 exceeded"},
+		{object.Limits{MaxDepth: 3}, "let f = fn(n) { if (n > 0) { 0 + f(n - 1) } else { 0 } }; f(2)", ""},
+		{object.Limits{MaxDepth: 3}, "let f = fn(n) { if (n > 0) { 0 + f(n - 1) } else { 0 } }; f(3)", "1:35: maximum call depth of 3 exceeded"},
+		{object.Limits{MaxSteps: 100}, "while (true) { }", "1:8: maximum of 100 evaluation steps exceeded"},
+		{object.Limits{Timeout: time.Millisecond}, "while (true) { }", "1:8: execution interrupted: context deadline exceeded"},
+		{object.Limits{MaxElements: 3}, "[1, 2, 3]", ""},
+		{object.Limits{MaxElements: 3}, "push([1, 2, 3], 4)", "1:5: ARRAY of size 4 exceeds the maximum of 3"},
+		{object.Limits{MaxElements: 3}, `"ab" + "cd"`, "1:6: STRING of size 4 exceeds the maximum of 3"},
+		{object.Limits{MaxElements: 1}, `let h = {}; h["a"] = 1; h["b"] = 2`, "1:32: HASH of size 2 exceeds the maximum of 1"},
+		{object.Limits{MaxElements: 1000}, "len(range(2000000000))", "1:10: ARRAY of size 2000000000 exceeds the maximum of 1000"},
+		{object.Limits{MaxElements: 1000}, `repeat("ab", 1000000000)`, "1:7: STRING of size 2000000000 exceeds the maximum of 1000"},
+		{object.Limits{MaxElements: 3}, "concat([1, 2], [3, 4])", "1:7: ARRAY of size 4 exceeds the maximum of 3"},
+		{object.Limits{MaxElements: 3}, "flatten([[1, 2], [3, 4]])", "1:8: ARRAY of size 4 exceeds the maximum of 3"},
+		{object.Limits{MaxElements: 3}, "map([[1, 2, 3]], fn(a) { push(a, 4) })", "1:30: ARRAY of size 4 exceeds the maximum of 3"},
+		{object.Limits{MaxElements: 3}, "zip([1], [2], [3], [4])", "1:4: ARRAY of size 4 exceeds the maximum of 3"},
+		{object.Limits{MaxSteps: 100}, "while (true) { try { 1 } catch (e) { 2 } }", "1:8: maximum of 100 evaluation steps exceeded"},
+		{object.Limits{MaxSteps: 100}, "let f = fn() { try { while (true) { } } catch (e) { 1 } finally { 2 } }; f()",
+			"1:35: maximum of 100 evaluation steps exceeded"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Limits Test Case %d", i), func(t *testing.T) {
+			in := New(WithLimits(c.limits))
+			_, err := in.Run(context.Background(), c.input)
+
+			if c.expected == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %s", err)
+				}
+				return
+			}
+
+			var runtimeErr *RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("Error is not a runtime error, got %v", err)
+			}
+
+			if runtimeErr.Err.Kind != object.LIMIT_ERROR {
+				t.Errorf("Incorrect error kind, got %q want %q", runtimeErr.Err.Kind, object.LIMIT_ERROR)
+			}
+
+			if err.Error() != c.expected {
+				t.Errorf("Incorrect error, got %q want %q", err.Error(), c.expected)
+			}
+		})
+	}
+
+	in := New(WithLimits(object.Limits{MaxDepth: 2}))
+	if _, err := in.Run(context.Background(), "let f = fn(n) { if (n > 0) { 0 + f(n - 1) } else { 0 } };"); err != nil {
+		t.Fatalf("Could not run program: %s", err)
+	}
+	if _, err := in.Call("f", 5); err == nil || err.Error() != "1:35: maximum call depth of 2 exceeded" {
+		t.Errorf("Incorrect error calling a function beyond the limits, got %v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+	if _, err := in.Run(context.Background(), `
+let allow = fn(request) { if (len(request["user"]) > 3) { true } else { request.size < 10 } };
+let add = fn(a, b) { a + b };
+let answer = 42;`); err != nil {
+		t.Fatalf("Could not run program: %s", err)
+	}
+
+	type request struct {
+		User string `gonkey:"user"`
+		Size int    `gonkey:"size"`
+	}
+
+	cases := []struct {
+		name        string
+		args        []any
+		expected    any
+		expectedErr string
+	}{
+		{"allow", []any{request{User: "admin", Size: 100}}, true, ""},
+		{"allow", []any{&request{User: "bob", Size: 100}}, false, ""},
+		{"add", []any{1, 2}, int64(3), ""},
+		{"add", []any{"a", "b"}, "ab", ""},
+		{"add", []any{1.5, 2}, 3.5, ""},
+		{"len", []any{[]string{"a", "b"}}, int64(2), ""},
+		{"add", []any{1}, nil, "wrong number of arguments, got 1 want 2"},
+		{"answer", nil, nil, "gonkey: answer is not a function, got INTEGER"},
+		{"missing", nil, nil, "gonkey: missing is not defined"},
+		{"add", []any{make(chan int), 1}, nil, "gonkey: cannot convert chan int to a Monkey value"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Call Test Case %d", i), func(t *testing.T) {
+			result, err := in.Call(c.name, c.args...)
+
+			if c.expectedErr != "" {
+				if err == nil || err.Error() != c.expectedErr {
+					t.Fatalf("Incorrect error, got %v want %q", err, c.expectedErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if FromObject(result) != c.expected {
+				t.Errorf("Incorrect result, got %#v want %#v", FromObject(result), c.expected)
+			}
+		})
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	in := New()
+
+	if err := in.SetGlobal("limits", map[string]int{"max": 3}); err != nil {
+		t.Fatalf("Could not set global: %s", err)
+	}
+
+	if _, err := in.Run(context.Background(), `let doubled = limits["max"] * 2;`); err != nil {
+		t.Fatalf("Could not run program: %s", err)
+	}
+
+	doubled, ok := in.GetGlobal("doubled")
+	if !ok || FromObject(doubled) != int64(6) {
+		t.Errorf("Incorrect global, got %v want %d", doubled, 6)
+	}
+
+	if _, ok := in.GetGlobal("missing"); ok {
+		t.Errorf("Unexpected global %q", "missing")
+	}
+
+	if err := in.SetGlobal("bad", struct{ C chan int }{}); err == nil {
+		t.Errorf("No error setting a value that cannot be converted")
+	}
+
+	if _, ok := New().GetGlobal("doubled"); ok {
+		t.Errorf("Globals are shared between interpreters")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var out bytes.Buffer
+	in := New(WithOutput(&out), WithBuiltin("twice", func(args ...object.Object) object.Object {
+		return &object.Array{Elements: append(args, args...)}
+	}))
+
+	cases := []struct {
+		name string
+		fn   any
+	}{
+		{"upper", strings.ToUpper},
+		{"sum", func(xs ...int) int {
+			total := 0
+			for _, x := range xs {
+				total += x
+			}
+			return total
+		}},
+		{"divide", func(a, b float64) (float64, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		}},
+		{"keys", func(m map[string]any) int { return len(m) }},
+	}
+
+	for _, c := range cases {
+		if err := in.Register(c.name, c.fn); err != nil {
+			t.Fatalf("Could not register %s: %s", c.name, err)
+		}
+	}
+
+	if err := in.Register("bad", 42); err == nil || err.Error() != "gonkey: bad is not a function, got int" {
+		t.Errorf("Incorrect error registering a value, got %v", err)
+	}
+
+	if err := in.Register("bad", func() (int, int) { return 0, 0 }); err == nil {
+		t.Errorf("No error registering a function returning two values")
+	}
+
+	program := `
+puts(upper("monkey"), sum(), sum(1, 2, 3), divide(1, 4), keys({"a": 1, "b": [2]}), twice(1));
+try { divide(1, 0) } catch (e) { puts(e) }
+try { upper(1) } catch (e) { puts(e) }
+try { sum(1, "2") } catch (e) { puts(e) }
+try { upper() } catch (e) { puts(e) }
+`
+	if _, err := in.Run(context.Background(), program); err != nil {
+		t.Fatalf("Could not run program: %s", err)
+	}
+
+	expected := `MONKEY
+0
+6
+0.25
+2
+[1, 1]
+Error: division by zero
+TypeError: argument 1 to 'upper' not supported: cannot convert INTEGER to string
+TypeError: argument 2 to 'sum' not supported: cannot convert STRING to int
+ArgumentError: wrong number of arguments, got 0 want 1
+`
+	if out.String() != expected {
+		t.Errorf("Incorrect output, got %q want %q", out.String(), expected)
+	}
+}
+
+func TestModuleBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	lib := "export let shout = fn(s) { puts(upper(s)) };"
+	if err := os.WriteFile(filepath.Join(dir, "lib.mk"), []byte(lib), 0o644); err != nil {
+		t.Fatalf("Could not write module: %s", err)
+	}
+
+	var out bytes.Buffer
+	in := New(WithOutput(&out))
+	if err := in.Register("upper", func(s string) string { return strings.ToUpper(s) + "!" }); err != nil {
+		t.Fatalf("Could not register upper: %s", err)
+	}
+
+	if _, err := in.Run(context.Background(), fmt.Sprintf(`let lib = import "%s"; lib.shout("monkey")`, filepath.Join(dir, "lib"))); err != nil {
+		t.Fatalf("Could not run program: %s", err)
+	}
+
+	if out.String() != "MONKEY!\n" {
+		t.Errorf("Incorrect output, got %q want %q", out.String(), "MONKEY!\n")
+	}
+}
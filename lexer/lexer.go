@@ -1,34 +1,105 @@
 package lexer
 
-import "github.com/benja-vq/gonkey/token"
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Mode changes what the lexer reports besides the tokens of the language.
+type Mode uint
+
+const (
+	ScanComments Mode = 1 << iota // Report comments as COMMENT tokens instead of skipping them
+)
 
 type Lexer struct {
 	input        string
-	position     int  // Current position in input
-	readPosition int  // Current reading position in input
-	char         byte // Character under examination.
+	filename     string
+	mode         Mode
+	position     int  // Current position in input, in bytes
+	readPosition int  // Current reading position in input, in bytes
+	char         rune // Character under examination, decoded from UTF-8
+	line         int  // Line of the character under examination, starting at 1
+	column       int  // Column of the character under examination in characters, starting at 1
+	errors       []string
+	unterminated bool // Whether the input ended inside a string or comment
+
+	interpolations []interpolation // Interpolated strings the expressions under examination are embedded in, innermost last
+}
+
+// interpolation tracks an expression embedded in a string with ${...}.
+type interpolation struct {
+	start token.Position // Position of the string's opening quote
+	depth int            // Braces opened in the expression and not closed yet
 }
 
 func NewLexer(input string) *Lexer {
+	return NewLexerWithFilename("", input)
+}
+
+// NewLexerWithFilename creates a lexer whose token positions report filename.
+func NewLexerWithFilename(filename, input string) *Lexer {
 
 	lexer := Lexer{
 		input:        input,
+		filename:     filename,
 		position:     0,
 		readPosition: 0,
+		line:         1,
+		column:       0,
 	}
 
 	lexer.readChar()
 	return &lexer
 }
 
+// SetMode changes what the following calls to NextToken report.
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
+// Errors returns the errors found in the input read so far, such as
+// unterminated comments or malformed escape sequences, prefixed with the
+// position they refer to.
+func (l *Lexer) Errors() []string {
+	return l.errors
+}
+
+// Unterminated reports whether the input read so far ends inside a string or
+// comment, which more input could close.
+func (l *Lexer) Unterminated() bool {
+	return l.unterminated
+}
+
+func (l *Lexer) errorf(pos token.Position, format string, args ...any) {
+	l.errors = append(l.errors, pos.String()+": "+fmt.Sprintf(format, args...))
+}
+
 func (l *Lexer) NextToken() (tok token.Token) {
 
 	l.skipWhitespace()
+	pos := l.currentPosition()
+
+	if l.atComment() {
+		tok.Type = token.COMMENT
+		tok.Literal = l.readComment()
+		tok.Pos = pos
+		return tok
+	}
 
 	switch l.char {
 	// ASCII
 	case 0:
 		tok = newToken(token.EOF)
+		for _, interp := range l.interpolations {
+			l.unterminated = true
+			l.errorf(interp.start, "unterminated string")
+		}
+		l.interpolations = nil
 	case 33:
 		if l.peekChar() == '=' {
 			tok = newToken(token.NOT_EQ)
@@ -37,22 +108,50 @@ func (l *Lexer) NextToken() (tok token.Token) {
 			tok = newToken(token.BANG)
 		}
 	case 34:
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Type, tok.Literal = l.readString(pos, true)
+	case 38:
+		if l.peekChar() == '&' {
+			tok = newToken(token.AND)
+			l.readChar()
+		} else {
+			tok = newToken(token.ILLEGAL)
+		}
 	case 40:
 		tok = newToken(token.LPAREN)
 	case 41:
 		tok = newToken(token.RPAREN)
 	case 42:
-		tok = newToken(token.ASTERISK)
+		if l.peekChar() == '=' {
+			tok = newToken(token.ASTERISK_ASSIGN)
+			l.readChar()
+		} else {
+			tok = newToken(token.ASTERISK)
+		}
 	case 43:
-		tok = newToken(token.PLUS)
+		if l.peekChar() == '=' {
+			tok = newToken(token.PLUS_ASSIGN)
+			l.readChar()
+		} else {
+			tok = newToken(token.PLUS)
+		}
 	case 44:
 		tok = newToken(token.COMMA)
 	case 45:
-		tok = newToken(token.MINUS)
+		if l.peekChar() == '=' {
+			tok = newToken(token.MINUS_ASSIGN)
+			l.readChar()
+		} else {
+			tok = newToken(token.MINUS)
+		}
+	case 46:
+		tok = newToken(token.DOT)
 	case 47:
-		tok = newToken(token.SLASH)
+		if l.peekChar() == '=' {
+			tok = newToken(token.SLASH_ASSIGN)
+			l.readChar()
+		} else {
+			tok = newToken(token.SLASH)
+		}
 	case 58:
 		tok = newToken(token.COLON)
 	case 59:
@@ -74,16 +173,42 @@ func (l *Lexer) NextToken() (tok token.Token) {
 		tok = newToken(token.RBRACKET)
 	case 123:
 		tok = newToken(token.LBRACE)
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].depth += 1
+		}
+	case 124:
+		if l.peekChar() == '|' {
+			tok = newToken(token.OR)
+			l.readChar()
+		} else {
+			tok = newToken(token.ILLEGAL)
+		}
 	case 125:
+		n := len(l.interpolations)
+		if n > 0 && l.interpolations[n-1].depth == 0 {
+			// Ends the expression embedded in a string, which goes on
+			start := l.interpolations[n-1].start
+			l.interpolations = l.interpolations[:n-1]
+			tok.Type, tok.Literal = l.readString(start, false)
+			break
+		}
+
 		tok = newToken(token.RBRACE)
+		if n > 0 {
+			l.interpolations[n-1].depth -= 1
+		}
+	case 96:
+		tok.Type = token.STRING
+		tok.Literal = l.readRawString()
 	default:
 		if isLetter(l.char) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.char) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL)
@@ -91,6 +216,7 @@ func (l *Lexer) NextToken() (tok token.Token) {
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
@@ -106,68 +232,316 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-// Read until we encounter a non-digit character, return the read number
-// 5432 != 2345
-// ^~~~
-func (l *Lexer) readNumber() string {
+// Read an integer or a float with an optional fraction and exponent, return
+// the read number and its token type
+// 5432 != 1.5e-3
+// ^~~~    ^~~~~~
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.INT
+
+	l.readDigits()
+
+	if l.char == '.' && isDigit(l.peekChar()) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+
+	if l.char == 'e' || l.char == 'E' {
+		next := l.peekChar()
+		signed := next == '+' || next == '-'
+		if isDigit(next) || signed && isDigit(l.peekCharAt(2)) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if signed {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.char) {
 		l.readChar()
 	}
+}
 
-	return l.input[position:l.position]
+// Read a string, or the part of an interpolated string, up to its closing
+// quote or the next ${, return the token type of what was read and its value
+// with the escape sequences \n, \t, \r, \\, \", \$ and \u{...} replaced by
+// what they stand for. start is the position of the opening quote, and first
+// whether the string starts at the character under examination rather than
+// at the } ending an embedded expression
+// "say \"hi\" to ${name}!"
+// ^~~~~~~~~~~~~~~~~     ^~~
+func (l *Lexer) readString(start token.Position, first bool) (token.TokenType, string) {
+	var value strings.Builder
+
+	for {
+		l.readChar()
+
+		switch {
+		case l.char == 0:
+			l.unterminated = true
+			l.errorf(start, "unterminated string")
+			return stringType(first, true), value.String()
+		case l.char == '"':
+			return stringType(first, true), value.String()
+		case l.char == '$' && l.peekChar() == '{':
+			l.readChar()
+			l.interpolations = append(l.interpolations, interpolation{start: start})
+			return stringType(first, false), value.String()
+		case l.char == '\\':
+			l.readEscape(&value)
+		default:
+			// Copied from the input to keep invalid UTF-8 as it is
+			value.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
 }
 
-func (l *Lexer) readString() string {
+// stringType returns the token type of the part of a string that is first or
+// not, and last or not.
+func stringType(first, last bool) token.TokenType {
+	switch {
+	case first && last:
+		return token.STRING
+	case first:
+		return token.STRING_START
+	case last:
+		return token.STRING_END
+	default:
+		return token.STRING_MIDDLE
+	}
+}
+
+// readEscape writes what the escape sequence starting at the backslash under
+// examination stands for to value, leaving its last character under
+// examination.
+func (l *Lexer) readEscape(value *strings.Builder) {
+	pos := l.currentPosition()
+
+	switch l.peekChar() {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '\\':
+		value.WriteByte('\\')
+	case '"':
+		value.WriteByte('"')
+	case '$':
+		value.WriteByte('$')
+	case 'u':
+		l.readChar()
+		value.WriteRune(l.readUnicodeEscape(pos))
+		return
+	case 0:
+		// Left for readString to report as unterminated
+		return
+	default:
+		l.errorf(pos, "invalid escape sequence \\%c", l.peekChar())
+	}
+
+	l.readChar()
+}
+
+// Read the code point of a \u{...} escape sequence, the u of which is under
+// examination, leaving its closing brace under examination
+// u{1F648}
+// ^~~~~~~~
+func (l *Lexer) readUnicodeEscape(pos token.Position) rune {
+	if l.peekChar() != '{' {
+		l.errorf(pos, "invalid Unicode escape, want \\u{...}")
+		return utf8.RuneError
+	}
+	l.readChar()
+
+	position := l.readPosition
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[position:l.readPosition]
+
+	if l.peekChar() != '}' {
+		l.errorf(pos, "invalid Unicode escape, want \\u{...}")
+		return utf8.RuneError
+	}
+	l.readChar()
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+		l.errorf(pos, "invalid Unicode code point \\u{%s}", digits)
+		return utf8.RuneError
+	}
+
+	return rune(code)
+}
+
+// Read a raw string up to its closing backtick, return its characters as they
+// are, newlines and backslashes included
+// `C:\monkey`
+// ^~~~~~~~~~~
+func (l *Lexer) readRawString() string {
+	pos := l.currentPosition()
 	position := l.position + 1
 
 	for {
 		l.readChar()
-		if l.char == '"' || l.char == 0 {
-			break
+
+		switch l.char {
+		case 0:
+			l.unterminated = true
+			l.errorf(pos, "unterminated raw string")
+			return l.input[position:l.position]
+		case '`':
+			return l.input[position:l.position]
 		}
 	}
+}
 
-	return l.input[position:l.position]
+// Read a line comment up to the end of its line, or a block comment up to the
+// */ closing it, block comments nesting, return the read comment
+// /* a /* nested */ comment */ x = 1 // one
+// ^~~~~~~~~~~~~~~~~~~~~~~~~~~~     ^~~~~~
+func (l *Lexer) readComment() string {
+	position := l.position
+	pos := l.currentPosition()
+
+	if l.peekChar() == '/' {
+		for l.char != '\n' && l.char != 0 {
+			l.readChar()
+		}
+
+		return l.input[position:l.position]
+	}
+
+	depth := 0
+	for {
+		switch {
+		case l.char == 0:
+			l.unterminated = true
+			l.errorf(pos, "unterminated comment")
+			return l.input[position:l.position]
+		case l.char == '/' && l.peekChar() == '*':
+			depth += 1
+			l.readChar()
+		case l.char == '*' && l.peekChar() == '/':
+			depth -= 1
+			l.readChar()
+		}
+
+		l.readChar()
+		if depth == 0 {
+			return l.input[position:l.position]
+		}
+	}
+}
+
+// atComment reports whether the character under examination starts a comment
+// that NextToken reports rather than skips.
+func (l *Lexer) atComment() bool {
+	return l.mode&ScanComments != 0 && isCommentStart(l.char, l.peekChar())
 }
 
-func isLetter(char byte) bool {
+func isCommentStart(char, next rune) bool {
+	//            '/'             '/'            '*'
+	return char == 47 && (next == 47 || next == 42)
+}
+
+// isLetter reports whether char can be part of an identifier, which Unicode
+// letters can besides ASCII ones and underscores.
+func isLetter(char rune) bool {
 	//             'a'           'z'           'A'           'Z'           '_'
-	return char >= 97 && char <= 122 || char >= 65 && char <= 90 || char == 95
+	return char >= 97 && char <= 122 || char >= 65 && char <= 90 || char == 95 ||
+		char >= utf8.RuneSelf && unicode.IsLetter(char)
 }
 
-// TODO: Support floats
-func isDigit(char byte) bool {
+func isDigit(char rune) bool {
 	//            '0'           '9'
 	return char >= 48 && char <= 57
 }
 
+func isHexDigit(char rune) bool {
+	//                             'a'           'f'           'A'           'F'
+	return isDigit(char) || char >= 97 && char <= 102 || char >= 65 && char <= 70
+}
+
+// skipWhitespace skips whitespace, and comments unless they are reported.
 func (l *Lexer) skipWhitespace() (tok token.Token) {
-	//           ' '            '\t'            '\n'            '\r'
-	for l.char == 32 || l.char == 9 || l.char == 10 || l.char == 13 {
-		l.readChar()
+	for {
+		switch {
+		//    ' '            '\t'            '\n'             '\r'
+		case l.char == 32 || l.char == 9 || l.char == 10 || l.char == 13:
+			l.readChar()
+		case l.mode&ScanComments == 0 && isCommentStart(l.char, l.peekChar()):
+			l.readComment()
+		default:
+			return tok
+		}
 	}
+}
 
-	return tok
+func (l *Lexer) peekChar() rune {
+	char, _ := l.decodeChar(l.readPosition)
+	return char
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
+// peekCharAt returns the character offset characters after the one under
+// examination, peekCharAt(1) being equivalent to peekChar.
+func (l *Lexer) peekCharAt(offset int) rune {
+	position := l.position
+	for ; offset > 0; offset-- {
+		_, size := l.decodeChar(position)
+		if size == 0 {
+			return 0
+		}
+		position += size
 	}
 
-	return l.input[l.readPosition]
+	char, _ := l.decodeChar(position)
+	return char
+}
+
+// decodeChar returns the character starting at position in the input and its
+// size in bytes, 0 and 0 past the end of the input. Invalid UTF-8 decodes to
+// utf8.RuneError one byte at a time.
+func (l *Lexer) decodeChar(position int) (rune, int) {
+	if position >= len(l.input) {
+		return 0, 0
+	}
+
+	return utf8.DecodeRuneInString(l.input[position:])
 }
 
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.char = 0
+	if l.char == '\n' {
+		l.line += 1
+		l.column = 1
 	} else {
-		l.char = l.input[l.readPosition]
+		l.column += 1
 	}
 
+	var size int
+	l.char, size = l.decodeChar(l.readPosition)
+
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += max(size, 1)
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
 func newToken(tokenType token.TokenType) token.Token {
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"fmt"
 	"github.com/benja-vq/gonkey/token"
 	"testing"
 )
@@ -14,7 +15,7 @@ let add = fn(x, y) {
 }
 
 let result = add(five, ten)#;
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -142,3 +143,411 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x + 10;
+"str"`
+
+	cases := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+		expectedOffset  int
+	}{
+		{expectedLiteral: "let", expectedLine: 1, expectedColumn: 1, expectedOffset: 0},
+		{expectedLiteral: "x", expectedLine: 1, expectedColumn: 5, expectedOffset: 4},
+		{expectedLiteral: "=", expectedLine: 1, expectedColumn: 7, expectedOffset: 6},
+		{expectedLiteral: "5", expectedLine: 1, expectedColumn: 9, expectedOffset: 8},
+		{expectedLiteral: ";", expectedLine: 1, expectedColumn: 10, expectedOffset: 9},
+		{expectedLiteral: "x", expectedLine: 2, expectedColumn: 3, expectedOffset: 13},
+		{expectedLiteral: "+", expectedLine: 2, expectedColumn: 5, expectedOffset: 15},
+		{expectedLiteral: "10", expectedLine: 2, expectedColumn: 7, expectedOffset: 17},
+		{expectedLiteral: ";", expectedLine: 2, expectedColumn: 9, expectedOffset: 19},
+		{expectedLiteral: "str", expectedLine: 3, expectedColumn: 1, expectedOffset: 21},
+		{expectedLiteral: "", expectedLine: 3, expectedColumn: 6, expectedOffset: 26},
+	}
+
+	lexer := NewLexerWithFilename("test.mk", input)
+
+	for i, tt := range cases {
+		tok := lexer.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Test case %d (literal) failed. got %q want %q",
+				i, tok.Literal, tt.expectedLiteral)
+		}
+
+		if tok.Pos.Filename != "test.mk" {
+			t.Errorf("Test case %d (filename) failed. got %q want %q",
+				i, tok.Pos.Filename, "test.mk")
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("Test case %d (position) failed. got %d:%d want %d:%d",
+				i, tok.Pos.Line, tok.Pos.Column, tt.expectedLine, tt.expectedColumn)
+		}
+
+		if tok.Pos.Offset != tt.expectedOffset {
+			t.Errorf("Test case %d (offset) failed. got %d want %d",
+				i, tok.Pos.Offset, tt.expectedOffset)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `5 3.14 0.5 1e3 1.5e-3 2E+10 7.foo 8e 9e-x`
+
+	cases := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{expectedType: token.INT, expectedLiteral: "5"},
+		{expectedType: token.FLOAT, expectedLiteral: "3.14"},
+		{expectedType: token.FLOAT, expectedLiteral: "0.5"},
+		{expectedType: token.FLOAT, expectedLiteral: "1e3"},
+		{expectedType: token.FLOAT, expectedLiteral: "1.5e-3"},
+		{expectedType: token.FLOAT, expectedLiteral: "2E+10"},
+		{expectedType: token.INT, expectedLiteral: "7"},
+		{expectedType: token.DOT, expectedLiteral: "."},
+		{expectedType: token.IDENT, expectedLiteral: "foo"},
+		{expectedType: token.INT, expectedLiteral: "8"},
+		{expectedType: token.IDENT, expectedLiteral: "e"},
+		{expectedType: token.INT, expectedLiteral: "9"},
+		{expectedType: token.IDENT, expectedLiteral: "e"},
+		{expectedType: token.MINUS, expectedLiteral: "-"},
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.EOF, expectedLiteral: ""},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range cases {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Test case %d (token type) failed. got %q want %q",
+				i, tok.Type.Literal(), tt.expectedType.Literal())
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Test case %d (literal) failed. got %q want %q",
+				i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	input := `while for in break continue forever throw try catch finally import export`
+
+	cases := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{expectedType: token.WHILE, expectedLiteral: "while"},
+		{expectedType: token.FOR, expectedLiteral: "for"},
+		{expectedType: token.IN, expectedLiteral: "in"},
+		{expectedType: token.BREAK, expectedLiteral: "break"},
+		{expectedType: token.CONTINUE, expectedLiteral: "continue"},
+		{expectedType: token.IDENT, expectedLiteral: "forever"},
+		{expectedType: token.THROW, expectedLiteral: "throw"},
+		{expectedType: token.TRY, expectedLiteral: "try"},
+		{expectedType: token.CATCH, expectedLiteral: "catch"},
+		{expectedType: token.FINALLY, expectedLiteral: "finally"},
+		{expectedType: token.IMPORT, expectedLiteral: "import"},
+		{expectedType: token.EXPORT, expectedLiteral: "export"},
+		{expectedType: token.EOF, expectedLiteral: ""},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range cases {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Test case %d (token type) failed. got %q want %q",
+				i, tok.Type.Literal(), tt.expectedType.Literal())
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Test case %d (literal) failed. got %q want %q",
+				i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+}
+
+func TestAssignmentTokens(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x == y && a || b & c`
+
+	cases := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.ASSIGN, expectedLiteral: "="},
+		{expectedType: token.INT, expectedLiteral: "1"},
+		{expectedType: token.SEMICOLON, expectedLiteral: ";"},
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.PLUS_ASSIGN, expectedLiteral: "+="},
+		{expectedType: token.INT, expectedLiteral: "2"},
+		{expectedType: token.SEMICOLON, expectedLiteral: ";"},
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.MINUS_ASSIGN, expectedLiteral: "-="},
+		{expectedType: token.INT, expectedLiteral: "3"},
+		{expectedType: token.SEMICOLON, expectedLiteral: ";"},
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.ASTERISK_ASSIGN, expectedLiteral: "*="},
+		{expectedType: token.INT, expectedLiteral: "4"},
+		{expectedType: token.SEMICOLON, expectedLiteral: ";"},
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.SLASH_ASSIGN, expectedLiteral: "/="},
+		{expectedType: token.INT, expectedLiteral: "5"},
+		{expectedType: token.SEMICOLON, expectedLiteral: ";"},
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.EQ, expectedLiteral: "=="},
+		{expectedType: token.IDENT, expectedLiteral: "y"},
+		{expectedType: token.AND, expectedLiteral: "&&"},
+		{expectedType: token.IDENT, expectedLiteral: "a"},
+		{expectedType: token.OR, expectedLiteral: "||"},
+		{expectedType: token.IDENT, expectedLiteral: "b"},
+		{expectedType: token.ILLEGAL, expectedLiteral: "ILLEGAL"},
+		{expectedType: token.IDENT, expectedLiteral: "c"},
+		{expectedType: token.EOF, expectedLiteral: ""},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range cases {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("Test case %d (token type) failed. got %q want %q",
+				i, tok.Type.Literal(), tt.expectedType.Literal())
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Test case %d (literal) failed. got %q want %q",
+				i, tok.Literal, tt.expectedLiteral)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `x // line /* not a block
+/ 2 /* block /* nested */ still
+comment */ y /= 1 // last`
+
+	cases := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		scanned         bool // Only reported when scanning comments
+	}{
+		{expectedType: token.IDENT, expectedLiteral: "x"},
+		{expectedType: token.COMMENT, expectedLiteral: "// line /* not a block", scanned: true},
+		{expectedType: token.SLASH, expectedLiteral: "/"},
+		{expectedType: token.INT, expectedLiteral: "2"},
+		{expectedType: token.COMMENT, expectedLiteral: "/* block /* nested */ still\ncomment */", scanned: true},
+		{expectedType: token.IDENT, expectedLiteral: "y"},
+		{expectedType: token.SLASH_ASSIGN, expectedLiteral: "/="},
+		{expectedType: token.INT, expectedLiteral: "1"},
+		{expectedType: token.COMMENT, expectedLiteral: "// last", scanned: true},
+		{expectedType: token.EOF, expectedLiteral: ""},
+	}
+
+	for _, mode := range []Mode{0, ScanComments} {
+		lexer := NewLexer(input)
+		lexer.SetMode(mode)
+
+		for i, tt := range cases {
+			if tt.scanned && mode != ScanComments {
+				continue
+			}
+
+			tok := lexer.NextToken()
+
+			if tok.Type != tt.expectedType {
+				t.Fatalf("Test case %d (token type, mode %d) failed. got %q want %q",
+					i, mode, tok.Type.Literal(), tt.expectedType.Literal())
+			}
+
+			if tok.Literal != tt.expectedLiteral {
+				t.Fatalf("Test case %d (literal, mode %d) failed. got %q want %q",
+					i, mode, tok.Literal, tt.expectedLiteral)
+			}
+		}
+
+		if len(lexer.Errors()) != 0 {
+			t.Errorf("Unexpected errors in mode %d: %v", mode, lexer.Errors())
+		}
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	lexer := NewLexerWithFilename("test.mk", "x\n  /* outer /* inner */")
+
+	if tok := lexer.NextToken(); tok.Type != token.IDENT {
+		t.Fatalf("Incorrect first token, got %q", tok.Type.Literal())
+	}
+	if tok := lexer.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("Incorrect token after the comment, got %q want EOF", tok.Type.Literal())
+	}
+
+	errors := lexer.Errors()
+	if len(errors) != 1 || errors[0] != "test.mk:2:3: unterminated comment" {
+		t.Errorf("Incorrect errors, got %q", errors)
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	input := "\"a\\tb\\n\" \"say \\\"hi\\\" \\\\o/\" \"\\u{48}\\u{e9}\\u{1F648}\" `raw \\n \"\nline` \"\" ``"
+
+	cases := []string{"a\tb\n", `say "hi" \o/`, "Hé🙈", "raw \\n \"\nline", "", ""}
+
+	lexer := NewLexer(input)
+
+	for i, expected := range cases {
+		tok := lexer.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Fatalf("Test case %d (type) failed. got %q want %q", i, tok.Type.Literal(), "STRING")
+		}
+
+		if tok.Literal != expected {
+			t.Errorf("Test case %d (literal) failed. got %q want %q", i, tok.Literal, expected)
+		}
+	}
+
+	if tok := lexer.NextToken(); tok.Type != token.EOF {
+		t.Errorf("Incorrect token after the strings, got %q want EOF", tok.Type.Literal())
+	}
+
+	if len(lexer.Errors()) != 0 {
+		t.Errorf("Unexpected errors: %q", lexer.Errors())
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	cases := []struct {
+		input        string
+		expected     string
+		unterminated bool
+	}{
+		{`x = "ab\q";`, `test.mk:1:8: invalid escape sequence \q`, false},
+		{`"\u48"`, `test.mk:1:2: invalid Unicode escape, want \u{...}`, false},
+		{`"\u{48"`, `test.mk:1:2: invalid Unicode escape, want \u{...}`, false},
+		{`"\u{}"`, `test.mk:1:2: invalid Unicode code point \u{}`, false},
+		{`"\u{D800}"`, `test.mk:1:2: invalid Unicode code point \u{D800}`, false},
+		{`"\u{0000041}"`, `test.mk:1:2: invalid Unicode code point \u{0000041}`, false},
+		{"x\n  \"abc", "test.mk:2:3: unterminated string", true},
+		{`"abc\"`, "test.mk:1:1: unterminated string", true},
+		{"`abc\n", "test.mk:1:1: unterminated raw string", true},
+		{"x = \"a ${b", "test.mk:1:5: unterminated string", true},
+		{"x = \"a ${b} c", "test.mk:1:5: unterminated string", true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("String Errors Test Case %d", i), func(t *testing.T) {
+			lexer := NewLexerWithFilename("test.mk", c.input)
+			for tok := lexer.NextToken(); tok.Type != token.EOF; tok = lexer.NextToken() {
+			}
+
+			errors := lexer.Errors()
+			if len(errors) != 1 || errors[0] != c.expected {
+				t.Errorf("Incorrect errors, got %q want %q", errors, c.expected)
+			}
+
+			if lexer.Unterminated() != c.unterminated {
+				t.Errorf("Incorrect unterminated, got %t want %t", lexer.Unterminated(), c.unterminated)
+			}
+		})
+	}
+}
+
+func TestUnicodeTokens(t *testing.T) {
+	input := "let café = \"héllo\";\nλ_1 + ü €"
+
+	cases := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+		expectedOffset  int
+	}{
+		{token.LET, "let", 1, 0},
+		{token.IDENT, "café", 5, 4},
+		{token.ASSIGN, "=", 10, 10},
+		{token.STRING, "héllo", 12, 12},
+		{token.SEMICOLON, ";", 19, 20},
+		{token.IDENT, "λ_", 1, 22},
+		{token.INT, "1", 3, 25},
+		{token.PLUS, "+", 5, 27},
+		{token.IDENT, "ü", 7, 29},
+		{token.ILLEGAL, "ILLEGAL", 9, 32},
+		{token.EOF, "", 10, 35},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range cases {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Test case %d (token) failed. got %q %q want %q %q",
+				i, tok.Type.Literal(), tok.Literal, tt.expectedType.Literal(), tt.expectedLiteral)
+		}
+
+		if tok.Pos.Column != tt.expectedColumn || tok.Pos.Offset != tt.expectedOffset {
+			t.Errorf("Test case %d (position) failed. got column %d offset %d want column %d offset %d",
+				i, tok.Pos.Column, tok.Pos.Offset, tt.expectedColumn, tt.expectedOffset)
+		}
+	}
+}
+
+func TestInterpolatedStringTokens(t *testing.T) {
+	input := `"Hi ${name}, ${len({"a": "${x}"}) + 1} \${no}!" "${a}"`
+
+	cases := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.STRING_START, "Hi ", 1},
+		{token.IDENT, "name", 7},
+		{token.STRING_MIDDLE, ", ", 11},
+		{token.IDENT, "len", 16},
+		{token.LPAREN, "(", 19},
+		{token.LBRACE, "{", 20},
+		{token.STRING, "a", 21},
+		{token.COLON, ":", 24},
+		{token.STRING_START, "", 26},
+		{token.IDENT, "x", 29},
+		{token.STRING_END, "", 30},
+		{token.RBRACE, "}", 32},
+		{token.RPAREN, ")", 33},
+		{token.PLUS, "+", 35},
+		{token.INT, "1", 37},
+		{token.STRING_END, " ${no}!", 38},
+		{token.STRING_START, "", 49},
+		{token.IDENT, "a", 52},
+		{token.STRING_END, "", 53},
+		{token.EOF, "", 55},
+	}
+
+	lexer := NewLexer(input)
+
+	for i, tt := range cases {
+		tok := lexer.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Test case %d (token) failed. got %q %q want %q %q",
+				i, tok.Type.Literal(), tok.Literal, tt.expectedType.Literal(), tt.expectedLiteral)
+		}
+
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("Test case %d (column) failed. got %d want %d", i, tok.Pos.Column, tt.expectedColumn)
+		}
+	}
+
+	if len(lexer.Errors()) != 0 {
+		t.Errorf("Unexpected errors: %q", lexer.Errors())
+	}
+}
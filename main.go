@@ -5,14 +5,63 @@ import (
 	"fmt"
 	"github.com/benja-vq/gonkey/config"
 	"github.com/benja-vq/gonkey/repl"
+	"github.com/benja-vq/gonkey/runner"
+	"io"
 	"os"
 	"os/user"
 )
 
+const usage = `Usage:
+  gonkey [flags]                    start the REPL, or run a program piped on stdin
+  gonkey [flags] -e 'program' [args...]
+  gonkey [flags] run script.mk [args...]
+  gonkey fmt [-w] [files...]        format programs, or the one piped on stdin
+
+Flags:
+`
+
 func main() {
 	config.Debug = flag.Bool("debug", false, "Prints debugging information during interpreter execution")
+	config.Engine = flag.String("engine", config.EngineEval,
+		"Backend used to execute programs: eval (tree-walking evaluator) or vm (bytecode virtual machine)")
+	config.TraceDepth = flag.Int("trace-depth", config.DefaultTraceDepth,
+		"Maximum number of calls shown in the stack trace of an error, 0 shows them all")
+	expr := flag.String("e", "", "Evaluates the given program and exits")
+	flag.Usage = func() {
+		_, _ = fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	if *config.Engine != config.EngineEval && *config.Engine != config.EngineVM {
+		_, _ = fmt.Fprintf(os.Stderr, "gonkey: unknown engine %q\n", *config.Engine)
+		flag.Usage()
+		os.Exit(runner.ExitUsage)
+	}
+
+	switch {
+	case *expr != "":
+		os.Exit(runner.Run("-e", *expr, flag.Args(), os.Stderr))
+	case flag.Arg(0) == "run":
+		if flag.NArg() < 2 {
+			flag.Usage()
+			os.Exit(runner.ExitUsage)
+		}
+		os.Exit(runner.RunFile(flag.Arg(1), flag.Args()[2:], os.Stderr))
+	case flag.Arg(0) == "fmt":
+		fmtFlags := flag.NewFlagSet("fmt", flag.ExitOnError)
+		write := fmtFlags.Bool("w", false, "Writes the result back to the files instead of stdout")
+		_ = fmtFlags.Parse(flag.Args()[1:])
+		os.Exit(runner.FormatFiles(fmtFlags.Args(), *write, os.Stdin, os.Stdout, os.Stderr))
+	case !isTerminal(os.Stdin):
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "gonkey: %s\n", err)
+			os.Exit(runner.ExitError)
+		}
+		os.Exit(runner.Run("<stdin>", string(src), flag.Args(), os.Stderr))
+	}
+
 	usr, err := user.Current()
 
 	if err != nil {
@@ -22,3 +71,12 @@ func main() {
 	fmt.Println("Type any commands to begin")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,147 @@
+// Package module finds, parses and caches the files programs import, leaving
+// their evaluation to the backend running the program.
+package module
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/lexer"
+	"github.com/benja-vq/gonkey/object"
+	"github.com/benja-vq/gonkey/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Extension is added to import paths that do not name a file with one.
+const Extension = ".mk"
+
+// PathVariable names the environment variable listing the directories searched
+// for imports that are not found next to the importing file.
+const PathVariable = "GONKEY_PATH"
+
+// LoadFunc evaluates the program of a module and returns its exports.
+type LoadFunc func(program *ast.Program) (map[string]object.Object, *object.Error)
+
+// Import returns the module path refers to when imported from the file named
+// importer, loading it with load the first time it is imported.
+func Import(cache *object.ModuleCache, path, importer string, load LoadFunc) (*object.Module, *object.Error) {
+	filename, err := Resolve(path, importer)
+	if err != nil {
+		return nil, importError("%s", err)
+	}
+
+	if mod, ok := cache.Get(filename); ok {
+		return mod, nil
+	}
+
+	if cycle, ok := cache.Begin(filename); !ok {
+		return nil, importError("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	var mod *object.Module
+	defer func() { cache.End(filename, mod) }()
+
+	program, err := Parse(filename)
+	if err != nil {
+		return nil, importError("%s", err)
+	}
+
+	exports, errObj := load(program)
+	if errObj != nil {
+		return nil, errObj
+	}
+
+	mod = &object.Module{Path: filename, Exports: exports}
+	return mod, nil
+}
+
+// Enter marks the file named filename, the entry script of a program, as being
+// evaluated in cache, so that a module importing it back is reported as an
+// import cycle instead of evaluating the script a second time. The returned
+// function marks the evaluation as finished. Names that are not files, like
+// the ones of scripts read from standard input, are left out of cache.
+func Enter(cache *object.ModuleCache, filename string) (exit func()) {
+	path, err := filepath.Abs(filename)
+	if err != nil || !isFile(path) {
+		return func() {}
+	}
+
+	cache.Begin(path)
+	return func() { cache.End(path, nil) }
+}
+
+// Resolve returns the file path refers to when imported from the file named
+// importer. Relative paths are looked up next to importer, or in the working
+// directory when importer is not a file, then in each GONKEY_PATH directory.
+func Resolve(path, importer string) (string, error) {
+	if filepath.Ext(path) == "" {
+		path += Extension
+	}
+
+	if filepath.IsAbs(path) {
+		if isFile(path) {
+			return filepath.Clean(path), nil
+		}
+		return "", fmt.Errorf("cannot find module %q", path)
+	}
+
+	dirs := []string{"."}
+	if isFile(importer) {
+		dirs[0] = filepath.Dir(importer)
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv(PathVariable))...)
+
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+
+		candidate := filepath.Join(dir, path)
+		if isFile(candidate) {
+			return filepath.Abs(candidate)
+		}
+	}
+
+	return "", fmt.Errorf("cannot find module %q", path)
+}
+
+// Parse reads and parses the module stored in filename.
+func Parse(filename string) (*ast.Program, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	l := lexer.NewLexerWithFilename(filename, string(src))
+	p := parser.NewParser(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("cannot parse module %s: %s", filename, strings.Join(p.Errors(), "; "))
+	}
+
+	return program, nil
+}
+
+// Exports returns the names bound by the export statements of program.
+func Exports(program *ast.Program) []string {
+	names := []string{}
+
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			names = append(names, export.Statement.Name.Value)
+		}
+	}
+
+	return names
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func importError(format string, a ...any) *object.Error {
+	return &object.Error{Kind: object.IMPORT_ERROR, Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,150 @@
+package module
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/object"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Could not create directory: %s", err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatalf("Could not write file: %s", err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	searchDir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.mk": "", "lib/a.mk": "", "lib/b.txt": ""})
+	writeFiles(t, searchDir, map[string]string{"std/c.mk": "", "lib/a.mk": ""})
+
+	t.Setenv(PathVariable, string(filepath.ListSeparator)+searchDir)
+	importer := filepath.Join(dir, "main.mk")
+
+	cases := []struct {
+		path     string
+		importer string
+		expected string
+	}{
+		{"lib/a", importer, filepath.Join(dir, "lib/a.mk")},
+		{"./lib/a.mk", importer, filepath.Join(dir, "lib/a.mk")},
+		{"lib/b.txt", importer, filepath.Join(dir, "lib/b.txt")},
+		{"std/c", importer, filepath.Join(searchDir, "std/c.mk")},
+		{"lib/a", "<stdin>", filepath.Join(searchDir, "lib/a.mk")},
+		{filepath.Join(dir, "lib/a"), "", filepath.Join(dir, "lib/a.mk")},
+		{"../a", filepath.Join(dir, "lib/a.mk"), filepath.Join(dir, "a.mk")},
+	}
+
+	writeFiles(t, dir, map[string]string{"a.mk": ""})
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Resolve Test Case %d", i), func(t *testing.T) {
+			resolved, err := Resolve(c.path, c.importer)
+			if err != nil {
+				t.Fatalf("Could not resolve %q: %s", c.path, err)
+			}
+
+			if resolved != c.expected {
+				t.Errorf("Incorrect resolved path, got %q want %q", resolved, c.expected)
+			}
+		})
+	}
+
+	if _, err := Resolve("missing", importer); err == nil || err.Error() != `cannot find module "missing.mk"` {
+		t.Errorf("Incorrect error for a missing module, got %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.mk": "",
+		"lib.mk":  "export let x = 1; let y = 2;",
+		"a.mk":    `import "b";`,
+		"b.mk":    `import "a";`,
+		"bad.mk":  "let x = ;",
+	})
+	importer := filepath.Join(dir, "main.mk")
+	cache := object.NewModuleCache()
+
+	loads := 0
+	var load LoadFunc
+	load = func(program *ast.Program) (map[string]object.Object, *object.Error) {
+		loads += 1
+
+		for _, stmt := range program.Statements {
+			es, ok := stmt.(*ast.ExpressionStatement)
+			if !ok {
+				continue
+			}
+			if ie, ok := es.Expression.(*ast.ImportExpression); ok {
+				if _, err := Import(cache, ie.Path, ie.Pos().Filename, load); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		exports := make(map[string]object.Object)
+		for _, name := range Exports(program) {
+			exports[name] = &object.Integer{Value: 1}
+		}
+		return exports, nil
+	}
+
+	first, err := Import(cache, "lib", importer, load)
+	if err != nil {
+		t.Fatalf("Could not import lib: %s", err.Message)
+	}
+
+	second, err := Import(cache, "./lib.mk", importer, load)
+	if err != nil {
+		t.Fatalf("Could not import lib again: %s", err.Message)
+	}
+
+	if first != second || loads != 1 {
+		t.Errorf("Module was not cached, loaded %d times", loads)
+	}
+
+	if names := first.Names(); len(names) != 1 || names[0] != "x" {
+		t.Errorf("Incorrect exports, got %v want %v", names, []string{"x"})
+	}
+
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"a", fmt.Sprintf("import cycle: %s -> %s -> %s",
+			filepath.Join(dir, "a.mk"), filepath.Join(dir, "b.mk"), filepath.Join(dir, "a.mk"))},
+		{"bad", fmt.Sprintf("cannot parse module %s: %s:1:9: No prefix parse function found for ;",
+			filepath.Join(dir, "bad.mk"), filepath.Join(dir, "bad.mk"))},
+		{"missing", `cannot find module "missing.mk"`},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Import Error Test Case %d", i), func(t *testing.T) {
+			_, err := Import(cache, c.path, importer, load)
+			if err == nil {
+				t.Fatalf("No error importing %q", c.path)
+			}
+
+			if err.Kind != object.IMPORT_ERROR {
+				t.Errorf("Incorrect error kind, got %q want %q", err.Kind, object.IMPORT_ERROR)
+			}
+
+			if err.Message != c.expected {
+				t.Errorf("Incorrect error message, got %q want %q", err.Message, c.expected)
+			}
+		})
+	}
+}
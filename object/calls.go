@@ -0,0 +1,46 @@
+package object
+
+import "github.com/benja-vq/gonkey/token"
+
+// Call is a call to a Monkey function that has not returned yet.
+type Call struct {
+	Function *Function
+	Args     []Object
+	Pos      token.Position // Position of the call
+}
+
+// CallStack keeps the calls being evaluated, so that a caught error can tell
+// the calls it was raised in besides the ones it unwound. Every environment of
+// a program shares the same call stack.
+type CallStack struct {
+	calls []Call // Innermost last
+}
+
+func NewCallStack() *CallStack {
+	return &CallStack{}
+}
+
+func (cs *CallStack) Push(call Call) {
+	cs.calls = append(cs.calls, call)
+}
+
+func (cs *CallStack) Pop() {
+	cs.calls[len(cs.calls)-1] = Call{}
+	cs.calls = cs.calls[:len(cs.calls)-1]
+}
+
+// Frames returns the stack frames of the calls being evaluated, innermost
+// first.
+func (cs *CallStack) Frames() []StackFrame {
+	frames := make([]StackFrame, 0, len(cs.calls))
+	for i := len(cs.calls) - 1; i >= 0; i -= 1 {
+		call := cs.calls[i]
+		frames = append(frames, StackFrame{
+			Function: call.Function.Name,
+			Pos:      call.Pos,
+			Args:     SummarizeArgs(call.Args),
+		})
+	}
+
+	return frames
+}
@@ -1,19 +1,37 @@
 package object
 
+import "sort"
+
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+
+	// Shared by every environment of a program
+	modules  *ModuleCache
+	limiter  *Limiter
+	builtins map[string]*Builtin
+	calls    *CallStack
 }
 
 func NewEnvironment() *Environment {
 	store := make(map[string]Object)
-	return &Environment{store: store, outer: nil}
+	return &Environment{store: store, outer: nil, modules: NewModuleCache(), limiter: NewLimiter(),
+		builtins: make(map[string]*Builtin), calls: NewCallStack()}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	store := make(map[string]Object)
+	return &Environment{store: store, outer: outer, modules: outer.modules, limiter: outer.limiter,
+		builtins: outer.builtins, calls: outer.calls}
+}
+
+// NewModuleEnvironment creates the global environment of a module imported
+// from a program running in env. It shares the imported modules, the limiter,
+// the builtins and the call stack of env but none of its bindings.
+func NewModuleEnvironment(env *Environment) *Environment {
+	store := make(map[string]Object)
+	return &Environment{store: store, outer: nil, modules: env.modules, limiter: env.limiter,
+		builtins: env.builtins, calls: env.calls}
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -28,3 +46,54 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Assign rebinds name in the innermost environment that already defines it.
+// It reports false, leaving every environment untouched, when no enclosing
+// environment has a binding for name.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return nil, false
+}
+
+func (e *Environment) Modules() *ModuleCache {
+	return e.modules
+}
+
+func (e *Environment) Limiter() *Limiter {
+	return e.limiter
+}
+
+func (e *Environment) Calls() *CallStack {
+	return e.calls
+}
+
+// SetBuiltin defines a builtin for every environment of the program, modules
+// included, that hides the evaluator's builtin of the same name but not the
+// bindings of the program.
+func (e *Environment) SetBuiltin(name string, builtin *Builtin) {
+	e.builtins[name] = builtin
+}
+
+// Builtin returns the builtin SetBuiltin defined under name.
+func (e *Environment) Builtin(name string) (*Builtin, bool) {
+	builtin, ok := e.builtins[name]
+	return builtin, ok
+}
+
+// Names returns the names bound in this environment, not including the ones
+// of outer environments, in alphabetical order.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,94 @@
+package object
+
+// Equal reports whether a and b are equal the way == compares them: numbers,
+// integers and floats alike, strings, booleans and nulls by value, arrays
+// element by element, hashes by their pairs whatever their order, and other
+// values by identity.
+func Equal(a, b Object) bool {
+	return equal(a, b, nil)
+}
+
+// comparison is a pair of arrays or hashes being compared.
+type comparison struct {
+	a, b Object
+}
+
+// equal compares a and b, outer holding the arrays and hashes they are nested
+// in. Comparing a pair again inside itself assumes it equal, which leaves the
+// rest of the comparison to decide for values that contain themselves.
+func equal(a, b Object, outer []comparison) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == b.Value
+		case *Float:
+			return float64(a.Value) == b.Value
+		}
+		return false
+	case *Float:
+		switch b := b.(type) {
+		case *Integer:
+			return a.Value == float64(b.Value)
+		case *Float:
+			return a.Value == b.Value
+		}
+		return false
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if comparing(a, b, outer) {
+			return true
+		}
+		outer = append(outer, comparison{a, b})
+		for i, el := range a.Elements {
+			if !equal(el, b.Elements[i], outer) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		if comparing(a, b, outer) {
+			return true
+		}
+		outer = append(outer, comparison{a, b})
+		for _, pair := range a.Pairs() {
+			value, ok := b.Get(pair.Key)
+			if !ok || !equal(pair.Value, value, outer) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func comparing(a, b Object, outer []comparison) bool {
+	for _, c := range outer {
+		if c.a == a && c.b == b {
+			return true
+		}
+	}
+
+	return false
+}
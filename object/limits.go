@@ -0,0 +1,122 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limits bounds the resources a program may use, a zero field meaning no
+// limit.
+type Limits struct {
+	MaxSteps    int64         // Nodes evaluated
+	MaxDepth    int           // Nested function calls
+	MaxElements int           // Elements of an array or hash, bytes of a string
+	Timeout     time.Duration // Wall-clock time
+}
+
+// DefaultLimits only bound the call depth, so that runaway recursion raises an
+// error instead of overflowing the Go stack.
+var DefaultLimits = Limits{MaxDepth: 10000}
+
+// checkInterval is the amount of steps between two checks of the context.
+const checkInterval = 256
+
+// Limiter accounts for the resources used by a program and reports the limit
+// it goes over. Every environment of a program shares the same limiter.
+type Limiter struct {
+	ctx    context.Context
+	limits Limits
+	steps  int64
+	depth  int
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{ctx: context.Background(), limits: DefaultLimits}
+}
+
+// Start resets the limiter for an evaluation bounded by ctx and limits. The
+// returned function must be called once the evaluation is over, it releases
+// the timeout and restores the limiter as it was before Start.
+func (l *Limiter) Start(ctx context.Context, limits Limits) (stop func()) {
+	previous := *l
+
+	cancel := context.CancelFunc(func() {})
+	if limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+	}
+
+	l.ctx = ctx
+	l.limits = limits
+	l.steps = 0
+
+	return func() {
+		cancel()
+		*l = previous
+	}
+}
+
+// Step accounts for the evaluation of a node, checking the context every few
+// steps.
+func (l *Limiter) Step() *Error {
+	if l.steps%checkInterval == 0 {
+		if err := l.ctx.Err(); err != nil {
+			return limitError("execution interrupted: %s", err)
+		}
+	}
+
+	l.steps += 1
+	if l.limits.MaxSteps > 0 && l.steps > l.limits.MaxSteps {
+		return limitError("maximum of %d evaluation steps exceeded", l.limits.MaxSteps)
+	}
+
+	return nil
+}
+
+// Enter accounts for a function call, which must be followed by Leave once the
+// call returns if it succeeds.
+func (l *Limiter) Enter() *Error {
+	if l.limits.MaxDepth > 0 && l.depth >= l.limits.MaxDepth {
+		return limitError("maximum call depth of %d exceeded", l.limits.MaxDepth)
+	}
+
+	l.depth += 1
+	return nil
+}
+
+func (l *Limiter) Leave() {
+	l.depth -= 1
+}
+
+// CheckSize reports arrays, hashes and strings larger than allowed.
+func (l *Limiter) CheckSize(obj Object) *Error {
+	if l.limits.MaxElements <= 0 {
+		return nil
+	}
+
+	switch obj := obj.(type) {
+	case *Array:
+		return l.CheckLength(obj.Type(), int64(len(obj.Elements)))
+	case *Hash:
+		return l.CheckLength(obj.Type(), int64(obj.Len()))
+	case *String:
+		return l.CheckLength(obj.Type(), int64(len(obj.Value)))
+	default:
+		return nil
+	}
+}
+
+// CheckLength reports an array, hash or string of type typ and the given size
+// larger than allowed, so that builtins can refuse to create it before
+// allocating it.
+func (l *Limiter) CheckLength(typ ObjectType, size int64) *Error {
+	if l.limits.MaxElements > 0 && size > int64(l.limits.MaxElements) {
+		return limitError("%s of size %d exceeds the maximum of %d", typ, size, l.limits.MaxElements)
+	}
+
+	return nil
+}
+
+func limitError(format string, a ...any) *Error {
+	return &Error{Kind: LIMIT_ERROR, Message: fmt.Sprintf(format, a...)}
+}
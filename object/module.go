@@ -0,0 +1,63 @@
+package object
+
+import "sort"
+
+// Module is the value of an import expression, holding the bindings the
+// imported file exported.
+type Module struct {
+	Path    string // Resolved path of the file the module was loaded from
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "module(" + m.Path + ")" }
+
+// Names returns the exported names in alphabetical order.
+func (m *Module) Names() []string {
+	names := make([]string, 0, len(m.Exports))
+	for name := range m.Exports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ModuleCache keeps the modules a program imported so that each file is
+// evaluated once, and the files being evaluated to detect import cycles.
+type ModuleCache struct {
+	modules map[string]*Module
+	loading []string // Paths of the modules being evaluated, outermost first
+}
+
+func NewModuleCache() *ModuleCache {
+	return &ModuleCache{modules: make(map[string]*Module)}
+}
+
+func (mc *ModuleCache) Get(path string) (*Module, bool) {
+	mod, ok := mc.modules[path]
+	return mod, ok
+}
+
+// Begin marks path as being evaluated. When path is already being evaluated it
+// returns false along with the chain of imports leading back to it.
+func (mc *ModuleCache) Begin(path string) ([]string, bool) {
+	for i, loading := range mc.loading {
+		if loading == path {
+			cycle := append([]string{}, mc.loading[i:]...)
+			return append(cycle, path), false
+		}
+	}
+
+	mc.loading = append(mc.loading, path)
+	return nil, true
+}
+
+// End marks the evaluation of path as finished, caching mod unless it is nil.
+func (mc *ModuleCache) End(path string, mod *Module) {
+	mc.loading = mc.loading[:len(mc.loading)-1]
+
+	if mod != nil {
+		mc.modules[path] = mod
+	}
+}
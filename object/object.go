@@ -2,17 +2,37 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/code"
+	"github.com/benja-vq/gonkey/token"
 	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 type ObjectType string
 type BuiltinFunction func(args ...Object) Object
 
+// CallFunction calls fn, a function or builtin, with args the way the backend
+// running the program does, returning an error for values that are not
+// functions.
+type CallFunction func(fn Object, args []Object) Object
+
+// HigherOrderFunction is a builtin calling the functions it is passed with
+// call.
+type HigherOrderFunction func(call CallFunction, args ...Object) Object
+
+// LimitedFunction is a builtin creating values whose size depends on its
+// arguments, which it checks against limiter before allocating them.
+type LimitedFunction func(limiter *Limiter, call CallFunction, args ...Object) Object
+
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -23,6 +43,30 @@ const (
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
 	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
+	ITERATOR_OBJ          = "ITERATOR"
+
+	BREAK_OBJ     = "BREAK"
+	CONTINUE_OBJ  = "CONTINUE"
+	TAIL_CALL_OBJ = "TAIL_CALL"
+
+	EXCEPTION_OBJ = "EXCEPTION"
+	MODULE_OBJ    = "MODULE"
+)
+
+// Kinds of errors. Thrown values are plain errors unless they say otherwise.
+const (
+	ERROR_KIND     = "Error"
+	TYPE_ERROR     = "TypeError"
+	NAME_ERROR     = "NameError"
+	INDEX_ERROR    = "IndexError"
+	ARGUMENT_ERROR = "ArgumentError"
+	RUNTIME_ERROR  = "RuntimeError"
+	IMPORT_ERROR   = "ImportError"
+	LIMIT_ERROR    = "LimitError" // Raised when a program goes over its Limits
 )
 
 type Object interface {
@@ -31,6 +75,7 @@ type Object interface {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -41,6 +86,28 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect formats the float so that lexing the result yields a FLOAT token
+// with the same value: it always has a fraction or an exponent.
+func (f *Float) Inspect() string {
+	format := byte('f')
+	if abs := math.Abs(f.Value); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	s := strconv.FormatFloat(f.Value, format, -1, 64)
+	if strings.ContainsAny(s, ".eNI") { // NaN and Inf have no literal form
+		return s
+	}
+
+	return s + ".0"
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -55,7 +122,32 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue signal a break or continue statement to the loop that
+// encloses it, the same way ReturnValue does for functions.
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+func (c *Continue) Inspect() string  { return "continue" }
+
+// TailCall is a call in tail position the evaluator has yet to perform. It is
+// handed back to the function that made it, which runs it in its own place so
+// that tail recursion does not grow the Go stack.
+type TailCall struct {
+	Function  *Function
+	Arguments []Object
+	Pos       token.Position // Position of the call
+}
+
+func (tc *TailCall) Type() ObjectType { return TAIL_CALL_OBJ }
+func (tc *TailCall) Inspect() string  { return "tail call" }
+
 type Function struct {
+	Name       string // Name the function literal was bound to with let, if any
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -88,7 +180,22 @@ func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn            BuiltinFunction
+	HigherOrderFn HigherOrderFunction // Called instead of Fn when set
+	LimitedFn     LimitedFunction     // Called instead of the others when set
+}
+
+// Call calls the builtin with args, call running the functions it is passed
+// and limiter bounding the size of what it creates.
+func (b *Builtin) Call(limiter *Limiter, call CallFunction, args ...Object) Object {
+	if b.LimitedFn != nil {
+		return b.LimitedFn(limiter, call, args...)
+	}
+	if b.HigherOrderFn != nil {
+		return b.HigherOrderFn(call, args...)
+	}
+
+	return b.Fn(args...)
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -99,18 +206,48 @@ type Array struct {
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
-func (a *Array) Inspect() string {
+func (a *Array) Inspect() string  { return inspect(a, nil) }
+
+// inspect returns what Inspect shows for obj, outer holding the arrays and
+// hashes it is nested in. An array or hash nested in itself shows as [...] or
+// {...} there.
+func inspect(obj Object, outer []Object) string {
 	var out bytes.Buffer
 
-	elements := make([]string, 0, len(a.Elements))
-	for _, elem := range a.Elements {
-		elements = append(elements, elem.Inspect())
+	switch obj := obj.(type) {
+	case *Array:
+		if slices.Contains(outer, Object(obj)) {
+			return "[...]"
+		}
+		outer = append(outer, obj)
+
+		elements := make([]string, 0, len(obj.Elements))
+		for _, elem := range obj.Elements {
+			elements = append(elements, inspect(elem, outer))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+	case *Hash:
+		if slices.Contains(outer, Object(obj)) {
+			return "{...}"
+		}
+		outer = append(outer, obj)
+
+		pairs := make([]string, 0, len(obj.pairs))
+		for _, pair := range obj.pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				inspect(pair.Key, outer), inspect(pair.Value, outer)))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+	default:
+		return obj.Inspect()
 	}
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
-
 	return out.String()
 }
 
@@ -131,10 +268,30 @@ func (b *Boolean) HashKey() HashKey {
 	return HashKey{Type: b.Type(), Value: value}
 }
 
+// maxExactInteger is the largest integer from which every smaller one can be
+// converted to a float and back.
+const maxExactInteger = 1 << 53
+
+// HashKey gives integers the key of the float they are equal to when they are
+// too large to be converted to it exactly.
 func (i *Integer) HashKey() HashKey {
+	if i.Value > maxExactInteger || i.Value < -maxExactInteger {
+		return (&Float{Value: float64(i.Value)}).HashKey()
+	}
+
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey gives floats with an integral value the key of the integer they are
+// equal to, so that 1 and 1.0 are the same hash key.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && math.Abs(f.Value) <= maxExactInteger {
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -142,30 +299,169 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// HashKey combines the hash keys of the elements, which AsHashable checks are
+// all hashable.
+func (a *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	for _, el := range a.Elements {
+		if el, ok := el.(Hashable); ok {
+			key := el.HashKey()
+			h.Write([]byte(key.Type))
+			h.Write(binary.LittleEndian.AppendUint64(nil, key.Value))
+		}
+	}
+
+	return HashKey{Type: a.Type(), Value: h.Sum64()}
+}
+
+// AsHashable returns obj as a hash key, reporting false for values that cannot
+// be one: those without a HashKey, and arrays holding them or themselves.
+func AsHashable(obj Object) (Hashable, bool) {
+	return asHashable(obj, nil)
+}
+
+func asHashable(obj Object, outer []*Array) (Hashable, bool) {
+	arr, ok := obj.(*Array)
+	if !ok {
+		key, ok := obj.(Hashable)
+		return key, ok
+	}
+
+	if slices.Contains(outer, arr) {
+		return nil, false
+	}
+	for _, el := range arr.Elements {
+		if _, ok := asHashable(el, append(outer, arr)); !ok {
+			return nil, false
+		}
+	}
+
+	return arr, true
+}
+
+// frozenKey returns key as a hash stores it, arrays being copied for changes
+// to the original not to move it to another bucket.
+func frozenKey(key Hashable) Hashable {
+	arr, ok := key.(*Array)
+	if !ok {
+		return key
+	}
+
+	elements := make([]Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		if el, ok := el.(Hashable); ok {
+			elements[i] = frozenKey(el)
+		} else {
+			elements[i] = el
+		}
+	}
+
+	return &Array{Elements: elements}
+}
+
 type HashPair struct {
-	Key   Object
+	Key   Hashable
 	Value Object
 }
 
+// Hash maps hashable keys to values, keeping its pairs in the order their keys
+// were first set in. Keys are grouped by HashKey, and told apart within a
+// group by comparing them with Equal, so colliding keys do not replace each
+// other while keys equal with ==, such as 1 and 1.0, are the same key.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair
+	buckets map[HashKey][]int      // Positions in pairs of the keys with each HashKey
+	hashKey func(Hashable) HashKey // Groups keys, Hashable.HashKey when nil
+}
+
+// NewHash returns an empty hash with room for size pairs.
+func NewHash(size int) *Hash {
+	return &Hash{pairs: make([]HashPair, 0, size), buckets: make(map[HashKey][]int, size)}
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
+func (h *Hash) Inspect() string  { return inspect(h, nil) }
+
+// Len returns the number of pairs in h.
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// Pairs returns the pairs of h in insertion order, the slice must not be
+// modified.
+func (h *Hash) Pairs() []HashPair { return h.pairs }
 
-	pairs := make([]string, 0, len(h.Pairs))
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+// Get returns the value of key in h, and whether h holds key at all.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	_, i := h.find(key)
+	if i < 0 {
+		return nil, false
 	}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+	return h.pairs[i].Value, true
+}
 
-	return out.String()
+// Set binds key to value in h. Keys set for the first time go after the others
+// while known ones keep their place.
+func (h *Hash) Set(key Hashable, value Object) {
+	bucket, i := h.find(key)
+	if i >= 0 {
+		h.pairs[i].Value = value
+		return
+	}
+
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+	h.buckets[bucket] = append(h.buckets[bucket], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: frozenKey(key), Value: value})
+}
+
+// Delete removes key from h, the pairs after it moving up a place.
+func (h *Hash) Delete(key Hashable) {
+	_, i := h.find(key)
+	if i < 0 {
+		return
+	}
+
+	h.pairs = slices.Delete(h.pairs, i, i+1)
+	h.reindex()
+}
+
+// Copy returns a hash holding the same pairs as h.
+func (h *Hash) Copy() *Hash {
+	hash := &Hash{pairs: slices.Clone(h.pairs), hashKey: h.hashKey}
+	hash.reindex()
+
+	return hash
+}
+
+// find returns the bucket of key and its position in h.pairs, -1 when h does
+// not hold it.
+func (h *Hash) find(key Hashable) (HashKey, int) {
+	bucket := h.bucketOf(key)
+	for _, i := range h.buckets[bucket] {
+		if Equal(h.pairs[i].Key, key) {
+			return bucket, i
+		}
+	}
+
+	return bucket, -1
+}
+
+func (h *Hash) bucketOf(key Hashable) HashKey {
+	if h.hashKey != nil {
+		return h.hashKey(key)
+	}
+
+	return key.HashKey()
+}
+
+// reindex rebuilds the buckets of h from its pairs.
+func (h *Hash) reindex() {
+	h.buckets = make(map[HashKey][]int, len(h.pairs))
+	for i, pair := range h.pairs {
+		bucket := h.bucketOf(pair.Key)
+		h.buckets[bucket] = append(h.buckets[bucket], i)
+	}
 }
 
 type Quote struct {
@@ -175,14 +471,235 @@ type Quote struct {
 func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
 func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.String() + ")" }
 
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := make([]string, 0, len(m.Parameters))
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+type CompiledFunction struct {
+	Name          string // Name the function literal was bound to with let, if any
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	Positions     map[int]token.Position // Source position by instruction offset
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+
+	// The constants and globals of the program the closure was created in,
+	// which differ from the caller's when it was exported by a module.
+	Constants []Object
+	Globals   []Object
+}
+
+// Type reports closures as functions, the VM counterpart of *Function.
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Cell boxes a local variable that is reassigned and captured by a closure,
+// so that the function defining it and its closures share a single binding.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string {
+	return fmt.Sprintf("Cell[%s]", c.Value.Inspect())
+}
+
+// Iterator holds the state of a for-in loop in the virtual machine.
+type Iterator struct {
+	Elements []Object
+	Index    int
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string {
+	return fmt.Sprintf("Iterator[%d/%d]", it.Index, len(it.Elements))
+}
+
+// Next returns the next element, or false once every element was visited.
+func (it *Iterator) Next() (Object, bool) {
+	if it.Index >= len(it.Elements) {
+		return nil, false
+	}
+
+	it.Index += 1
+	return it.Elements[it.Index-1], true
+}
+
 type Error struct {
 	Message string
+	Kind    string         // One of the error kinds, such as TYPE_ERROR
+	Pos     token.Position // Where the error was raised, if known
+	Stack   []StackFrame   // Calls the error unwound, innermost first
+	Elided  int            // Calls left out of Stack by the depth cap
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+
+	return "ERROR: " + e.Message
+}
+
+// PushFrame records that the error unwound the call described by frame. Once
+// the stack holds maxDepth frames the outer calls are only counted, a maxDepth
+// of 0 keeps every frame.
+func (e *Error) PushFrame(frame StackFrame, maxDepth int) {
+	if maxDepth > 0 && len(e.Stack) >= maxDepth {
+		e.Elided += 1
+		return
+	}
+
+	e.Stack = append(e.Stack, frame)
+}
+
+// StackTrace formats the calls the error unwound like a Go panic trace, each
+// call followed by its indented position. It is empty when the error was not
+// raised inside a function.
+//
+//	f(1, "a")
+//		script.mk:2:5
+func (e *Error) StackTrace() string {
+	if len(e.Stack) == 0 {
+		return ""
+	}
+
+	var out bytes.Buffer
+
+	out.WriteString("\nstack trace:\n")
+	for _, frame := range e.Stack {
+		out.WriteString(frame.Call() + "\n")
+		out.WriteString("\t" + frame.Pos.String() + "\n")
+	}
+
+	if e.Elided > 0 {
+		out.WriteString(fmt.Sprintf("...%d additional frames elided...\n", e.Elided))
+	}
+
+	return out.String()
+}
+
+// StackFrame is a call to a Monkey function an error went through.
+type StackFrame struct {
+	Function string         // Name of the called function, empty when unknown
+	Pos      token.Position // Position of the call
+	Args     string         // Summary of the arguments, see SummarizeArgs
+}
 
-// TODO: Incorporate line and column numbers in the lexer to give better error messages
-func (e *Error) Inspect() string { return "ERROR: " + e.Message }
+func (sf StackFrame) String() string {
+	return sf.name() + " (" + sf.Pos.String() + ")"
+}
+
+// Call returns the frame the way the call could be written, such as f(1, "a").
+func (sf StackFrame) Call() string {
+	return sf.name() + "(" + sf.Args + ")"
+}
+
+func (sf StackFrame) name() string {
+	if sf.Function == "" {
+		return "<anonymous>"
+	}
+
+	return sf.Function
+}
+
+const (
+	maxSummaryArgs  = 4  // Arguments shown before the rest is elided
+	maxSummaryWidth = 20 // Characters of an argument shown before it is cut
+)
+
+// SummarizeArgs describes the arguments of a call for a stack trace. Strings
+// are quoted, long values are cut and only the first few arguments are kept.
+func SummarizeArgs(args []Object) string {
+	summaries := []string{}
+
+	for i, arg := range args {
+		if i == maxSummaryArgs {
+			summaries = append(summaries, "...")
+			break
+		}
+
+		var summary string
+		if str, ok := arg.(*String); ok {
+			summary = strconv.Quote(str.Value)
+		} else {
+			summary = arg.Inspect()
+		}
+
+		if runes := []rune(summary); len(runes) > maxSummaryWidth {
+			summary = string(runes[:maxSummaryWidth]) + "..."
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return strings.Join(summaries, ", ")
+}
+
+// Exception is an error caught by a try expression. Its kind, message and
+// stack are read like the keys of a hash, and throwing it raises the error
+// again.
+type Exception struct {
+	Error   *Error
+	Callers []StackFrame // Calls the try expression runs in, innermost first
+}
+
+func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
+func (e *Exception) Inspect() string {
+	return e.Error.Kind + ": " + e.Error.Message
+}
+
+// Field returns the value of the "kind", "message" or "stack" key, the stack
+// being an array of strings describing the calls the error was raised in: the
+// ones it unwound, then the ones it was caught in.
+func (e *Exception) Field(key string) (Object, bool) {
+	switch key {
+	case "kind":
+		return &String{Value: e.Error.Kind}, true
+	case "message":
+		return &String{Value: e.Error.Message}, true
+	case "stack":
+		frames := make([]Object, 0, len(e.Error.Stack)+len(e.Callers))
+		for _, frame := range slices.Concat(e.Error.Stack, e.Callers) {
+			frames = append(frames, &String{Value: frame.String()})
+		}
+		return &Array{Elements: frames}, true
+	default:
+		return nil, false
+	}
+}
 
 type Null struct{}
 
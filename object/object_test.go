@@ -1,6 +1,11 @@
 package object
 
-import "testing"
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World!"}
@@ -16,14 +21,88 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("Strings with the same content have different hash keys")
 	}
 
-	// There is a (low) probability that this happens (hash collision).
-	// TODO: Collision resolution
+	// Collisions are possible, if unlikely, and left to Hash to resolve
 	if hello1.HashKey() == diff1.HashKey() {
 		t.Errorf("Strings with different content have same hash keys")
 	}
 
 }
 
+func TestExceptionFields(t *testing.T) {
+	exception := &Exception{Error: &Error{
+		Message: "boom",
+		Kind:    TYPE_ERROR,
+		Stack:   []StackFrame{{Function: "f"}, {}},
+	}}
+
+	cases := []struct {
+		key      string
+		expected string
+	}{
+		{"kind", "TypeError"},
+		{"message", "boom"},
+		{"stack", "[f (-), <anonymous> (-)]"},
+	}
+
+	for _, c := range cases {
+		value, ok := exception.Field(c.key)
+		if !ok {
+			t.Fatalf("Exception has no %q field", c.key)
+		}
+
+		if value.Inspect() != c.expected {
+			t.Errorf("Incorrect %q field, got %q want %q", c.key, value.Inspect(), c.expected)
+		}
+	}
+
+	if _, ok := exception.Field("position"); ok {
+		t.Errorf("Exception has an unexpected %q field", "position")
+	}
+}
+
+func TestSummarizeArgs(t *testing.T) {
+	cyclic := &Array{Elements: []Object{&Integer{Value: 0}}}
+	cyclic.Elements[0] = cyclic
+
+	cases := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{}, ""},
+		{[]Object{&Integer{Value: 1}, &String{Value: "a"}}, `1, "a"`},
+		{[]Object{&String{Value: "a string longer than the cap"}}, `"a string longer tha...`},
+		{[]Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}, &Integer{Value: 4}, &Integer{Value: 5}},
+			"1, 2, 3, 4, ..."},
+		{[]Object{cyclic}, "[[...]]"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Summarize Args Test Case %d", i), func(t *testing.T) {
+			summary := SummarizeArgs(c.args)
+			if summary != c.expected {
+				t.Errorf("Incorrect summary, got %q want %q", summary, c.expected)
+			}
+		})
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	err := &Error{Message: "boom"}
+	if err.StackTrace() != "" {
+		t.Errorf("Incorrect stack trace without frames, got %q", err.StackTrace())
+	}
+
+	for i := 0; i < 3; i++ {
+		err.PushFrame(StackFrame{Function: "f", Args: fmt.Sprint(i)}, 2)
+	}
+	err.PushFrame(StackFrame{}, 2)
+
+	expected := "\nstack trace:\nf(0)\n\t-\nf(1)\n\t-\n...2 additional frames elided...\n"
+	if err.StackTrace() != expected {
+		t.Errorf("Incorrect stack trace, got %q want %q", err.StackTrace(), expected)
+	}
+}
+
 func TestBooleanHashKey(t *testing.T) {
 	true1 := &Boolean{Value: true}
 	true2 := &Boolean{Value: true}
@@ -61,3 +140,218 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("Integers with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &Float{Value: 0.5}
+	half2 := &Float{Value: 0.5}
+	zero := &Float{Value: 0}
+	negZero := &Float{Value: math.Copysign(0, -1)}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("Floats with same content have different hash keys")
+	}
+
+	if half1.HashKey() == zero.HashKey() {
+		t.Errorf("Floats with different content have same hash keys")
+	}
+
+	if zero.HashKey() != negZero.HashKey() {
+		t.Errorf("0.0 and -0.0 have different hash keys")
+	}
+
+	for _, value := range []int64{1, -3, 1 << 53, 1<<53 + 1, math.MaxInt64} {
+		i, f := &Integer{Value: value}, &Float{Value: float64(value)}
+		if i.HashKey() != f.HashKey() {
+			t.Errorf("%d and %s have different hash keys", value, f.Inspect())
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	cases := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{-2, "-2.0"},
+		{0.5, "0.5"},
+		{1234567.25, "1234567.25"},
+		{1e21, "1e+21"},
+		{1.5e-7, "1.5e-07"},
+		{math.Inf(1), "+Inf"},
+	}
+
+	for _, c := range cases {
+		f := &Float{Value: c.value}
+		if f.Inspect() != c.expected {
+			t.Errorf("Incorrect float inspect, got %s want %s", f.Inspect(), c.expected)
+		}
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	shared := &Array{Elements: []Object{&Integer{Value: 1}}}
+	arr := &Array{Elements: []Object{shared, shared, nil}}
+	hash := NewHash(0)
+	hash.Set(&String{Value: "self"}, hash)
+	hash.Set(&String{Value: "arr"}, arr)
+	arr.Elements[2] = hash
+
+	expected := "[[1], [1], {self: {...}, arr: [...]}]"
+	if arr.Inspect() != expected {
+		t.Errorf("Incorrect inspect, got %s want %s", arr.Inspect(), expected)
+	}
+
+	expected = "{self: {...}, arr: [[1], [1], {...}]}"
+	if hash.Inspect() != expected {
+		t.Errorf("Incorrect inspect, got %s want %s", hash.Inspect(), expected)
+	}
+}
+
+func TestAsHashable(t *testing.T) {
+	cyclic := &Array{}
+	cyclic.Elements = []Object{cyclic}
+
+	cases := []struct {
+		obj      Object
+		expected bool
+	}{
+		{&String{Value: "a"}, true},
+		{&Integer{Value: 1}, true},
+		{&Array{}, true},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Array{Elements: []Object{&String{Value: "a"}}}}}, true},
+		{&Null{}, false},
+		{&Hash{}, false},
+		{&Array{Elements: []Object{&Integer{Value: 1}, &Hash{}}}, false},
+		{cyclic, false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("As Hashable Test Case %d", i), func(t *testing.T) {
+			if _, ok := AsHashable(c.obj); ok != c.expected {
+				t.Errorf("Incorrect result for %T, got %t want %t", c.obj, ok, c.expected)
+			}
+		})
+	}
+}
+
+// fuzzKey returns one of 24 distinct keys: strings, integers and arrays.
+func fuzzKey(id byte) Hashable {
+	switch id % 24 / 8 {
+	case 0:
+		return &String{Value: fmt.Sprint("k", id%8)}
+	case 1:
+		return &Integer{Value: int64(id % 8)}
+	default:
+		return &Array{Elements: []Object{&Integer{Value: int64(id % 8)}, &String{Value: "k"}}}
+	}
+}
+
+// FuzzHashCollisions runs the operations encoded in ops, pairs of an operation
+// and a key, on a hash whose keys all fall in two buckets, checking it against
+// a Go map.
+func FuzzHashCollisions(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 1, 2, 0, 2, 1})
+	f.Add([]byte{0, 3, 0, 11, 0, 19, 1, 11, 2, 3, 2, 19, 0, 11})
+	f.Add([]byte{0, 1, 0, 2, 0, 1, 1, 2, 1, 1, 2, 2})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		hash := &Hash{hashKey: func(key Hashable) HashKey {
+			return HashKey{Value: key.HashKey().Value % 2}
+		}}
+		values := map[byte]int64{}
+		order := []byte{}
+
+		for i := 0; i+1 < len(ops); i += 2 {
+			id := ops[i+1] % 24
+			key := fuzzKey(id)
+
+			switch ops[i] % 3 {
+			case 0:
+				hash.Set(key, &Integer{Value: int64(i)})
+				if _, ok := values[id]; !ok {
+					order = append(order, id)
+				}
+				values[id] = int64(i)
+			case 1:
+				hash.Delete(key)
+				if _, ok := values[id]; ok {
+					delete(values, id)
+					order = slices.DeleteFunc(order, func(other byte) bool { return other == id })
+				}
+			case 2:
+				value, ok := hash.Get(key)
+				expected, expectedOk := values[id]
+				if ok != expectedOk || (ok && value.(*Integer).Value != expected) {
+					t.Fatalf("Incorrect value for %s, got %v (%t) want %d (%t)",
+						key.Inspect(), value, ok, expected, expectedOk)
+				}
+			}
+
+			if hash.Len() != len(order) {
+				t.Fatalf("Incorrect length, got %d want %d", hash.Len(), len(order))
+			}
+			for j, pair := range hash.Pairs() {
+				if !Equal(pair.Key, fuzzKey(order[j])) || pair.Value.(*Integer).Value != values[order[j]] {
+					t.Fatalf("Incorrect pair %d, got %s: %s want %s: %d", j,
+						pair.Key.Inspect(), pair.Value.Inspect(), fuzzKey(order[j]).Inspect(), values[order[j]])
+				}
+			}
+		}
+	})
+}
+
+func TestEqual(t *testing.T) {
+	cyclic1 := &Array{Elements: []Object{&Integer{Value: 1}}}
+	cyclic1.Elements = append(cyclic1.Elements, cyclic1)
+	cyclic2 := &Array{Elements: []Object{&Integer{Value: 1}}}
+	cyclic2.Elements = append(cyclic2.Elements, cyclic2)
+	cyclic3 := &Array{Elements: []Object{&Integer{Value: 2}}}
+	cyclic3.Elements = append(cyclic3.Elements, cyclic3)
+
+	hash1 := NewHash(2)
+	hash1.Set(&String{Value: "a"}, &Integer{Value: 1})
+	hash1.Set(&String{Value: "b"}, &Array{Elements: []Object{&Null{}}})
+	hash2 := NewHash(2)
+	hash2.Set(&String{Value: "b"}, &Array{Elements: []Object{&Null{}}})
+	hash2.Set(&String{Value: "a"}, &Float{Value: 1})
+	hash3 := NewHash(1)
+	hash3.Set(&String{Value: "a"}, &Integer{Value: 1})
+	selfHash := NewHash(1)
+	selfHash.Set(&String{Value: "self"}, selfHash)
+
+	fn := &Builtin{}
+
+	cases := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Integer{Value: 1}, &Integer{Value: 1}, true},
+		{&Integer{Value: 1}, &Float{Value: 1}, true},
+		{&Float{Value: 0.5}, &Integer{Value: 0}, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "1"}, &Integer{Value: 1}, false},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Boolean{Value: false}, &Null{}, false},
+		{&Null{}, &Null{}, true},
+		{&Array{}, &Array{}, true},
+		{&Array{Elements: []Object{&Integer{Value: 1}}}, &Array{Elements: []Object{&Integer{Value: 2}}}, false},
+		{&Array{}, NewHash(0), false},
+		{hash1, hash2, true},
+		{hash1, hash3, false},
+		{selfHash, selfHash, true},
+		{cyclic1, cyclic2, true},
+		{cyclic1, cyclic3, false},
+		{fn, fn, true},
+		{fn, &Builtin{}, false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Equal Test Case %d", i), func(t *testing.T) {
+			if Equal(c.a, c.b) != c.expected {
+				t.Errorf("Incorrect equality of %T and %T, got %t want %t",
+					c.a, c.b, !c.expected, c.expected)
+			}
+		})
+	}
+}
@@ -18,6 +18,8 @@ type Parser struct {
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	loopDepth int // Loops enclosing the current token within its function
 }
 
 type (
@@ -28,6 +30,9 @@ type (
 // Precedences
 const (
 	LOWEST      = iota
+	ASSIGN      // x = y or x += y
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
 	SUM         // +
@@ -38,16 +43,24 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              OR,
+	token.AND:             AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 func NewParser(l *lexer.Lexer) *Parser {
@@ -57,9 +70,10 @@ func NewParser(l *lexer.Lexer) *Parser {
 		errors: []string{},
 	}
 
-	parser.prefixParseFns = make(map[token.TokenType]prefixParseFn, 12)
+	parser.prefixParseFns = make(map[token.TokenType]prefixParseFn, 15)
 	parser.registerPrefix(token.IDENT, parser.parseIdentifier)
 	parser.registerPrefix(token.INT, parser.parseIntegerLiteral)
+	parser.registerPrefix(token.FLOAT, parser.parseFloatLiteral)
 	parser.registerPrefix(token.BANG, parser.parsePrefixExpression)
 	parser.registerPrefix(token.MINUS, parser.parsePrefixExpression)
 	parser.registerPrefix(token.TRUE, parser.parseBoolean)
@@ -68,20 +82,32 @@ func NewParser(l *lexer.Lexer) *Parser {
 	parser.registerPrefix(token.IF, parser.parseIfExpression)
 	parser.registerPrefix(token.FUNCTION, parser.parseFunctionLiteral)
 	parser.registerPrefix(token.STRING, parser.parseStringLiteral)
+	parser.registerPrefix(token.STRING_START, parser.parseInterpolatedString)
 	parser.registerPrefix(token.LBRACKET, parser.parseArrayLiteral)
 	parser.registerPrefix(token.LBRACE, parser.parseHashLiteral)
+	parser.registerPrefix(token.MACRO, parser.parseMacroLiteral)
+	parser.registerPrefix(token.TRY, parser.parseTryExpression)
+	parser.registerPrefix(token.IMPORT, parser.parseImportExpression)
 
-	parser.infixParseFns = make(map[token.TokenType]infixParseFn, 10)
+	parser.infixParseFns = make(map[token.TokenType]infixParseFn, 15)
 	parser.registerInfix(token.PLUS, parser.parseInfixExpression)
 	parser.registerInfix(token.MINUS, parser.parseInfixExpression)
 	parser.registerInfix(token.SLASH, parser.parseInfixExpression)
 	parser.registerInfix(token.ASTERISK, parser.parseInfixExpression)
 	parser.registerInfix(token.EQ, parser.parseInfixExpression)
 	parser.registerInfix(token.NOT_EQ, parser.parseInfixExpression)
+	parser.registerInfix(token.AND, parser.parseInfixExpression)
+	parser.registerInfix(token.OR, parser.parseInfixExpression)
 	parser.registerInfix(token.LT, parser.parseInfixExpression)
 	parser.registerInfix(token.GT, parser.parseInfixExpression)
 	parser.registerInfix(token.LPAREN, parser.parseCallExpression)
 	parser.registerInfix(token.LBRACKET, parser.parseIndexExpression)
+	parser.registerInfix(token.DOT, parser.parseDotExpression)
+	parser.registerInfix(token.ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.PLUS_ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.MINUS_ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.ASTERISK_ASSIGN, parser.parseAssignExpression)
+	parser.registerInfix(token.SLASH_ASSIGN, parser.parseAssignExpression)
 
 	// Initialize currToken and peekToken
 	parser.nextToken()
@@ -90,18 +116,28 @@ func NewParser(l *lexer.Lexer) *Parser {
 	return parser
 }
 
+// Errors returns the errors found by the lexer, then those of the parser.
 func (p *Parser) Errors() []string {
-	return p.errors
+	lexerErrors := p.l.Errors()
+	if len(lexerErrors) == 0 {
+		return p.errors
+	}
+
+	return append(append([]string{}, lexerErrors...), p.errors...)
 }
 
 func (p *Parser) noPrefixParseFnError(tt token.TokenType) {
-	msg := fmt.Sprintf("No prefix parse function found for %s", tt)
-	p.errors = append(p.errors, msg)
+	p.errorf(p.currToken.Pos, "No prefix parse function found for %s", tt)
 }
 
 func (p *Parser) peekError(tt token.TokenType) {
-	msg := fmt.Sprintf("Peeking returned an incorrect token, got %s want %s",
+	p.errorf(p.peekToken.Pos, "Peeking returned an incorrect token, got %s want %s",
 		p.peekToken.Type, tt)
+}
+
+// errorf records a parser error prefixed with the source position it refers to.
+func (p *Parser) errorf(pos token.Position, format string, a ...any) {
+	msg := pos.String() + ": " + fmt.Sprintf(format, a...)
 	p.errors = append(p.errors, msg)
 }
 
@@ -115,7 +151,12 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for !p.currTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		var stmt ast.Statement
+		if p.currTokenIs(token.EXPORT) {
+			stmt = p.parseExportStatement()
+		} else {
+			stmt = p.parseStatement()
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -132,6 +173,17 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.EXPORT:
+		p.errorf(p.currToken.Pos, "Cannot use export outside of the top level")
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -155,6 +207,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -176,6 +232,128 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.currToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseExportStatement is only called for top level statements, the only ones
+// that can be exported.
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.currToken}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.currToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.currToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Variable = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth += 1
+	defer func() { p.loopDepth -= 1 }()
+
+	return p.parseBlockStatement()
+}
+
+// parseLoopControlStatement parses break and continue, which are only allowed
+// inside a loop of the function they appear in.
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.currToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if p.loopDepth == 0 {
+		p.errorf(tok.Pos, "Cannot use %s outside of a loop", tok.Literal)
+		return nil
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	if config.Debug != nil && *config.Debug {
 		defer untrace(trace("parseExpressionStatement"))
@@ -227,8 +405,26 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("Could not parse %q as an integer",
-			p.currToken.Literal))
+		p.errorf(p.currToken.Pos, "Could not parse %q as an integer",
+			p.currToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	if config.Debug != nil && *config.Debug {
+		defer untrace(trace("parseFloatLiteral"))
+	}
+	lit := &ast.FloatLiteral{Token: p.currToken}
+
+	value, err := strconv.ParseFloat(p.currToken.Literal, 64)
+	if err != nil {
+		p.errorf(p.currToken.Pos, "Could not parse %q as a float",
+			p.currToken.Literal)
 		return nil
 	}
 
@@ -270,6 +466,31 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseAssignExpression parses the value with the lowest precedence so that
+// assignments are right associative: a = b = c is a = (b = c).
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	if config.Debug != nil && *config.Debug {
+		defer untrace(trace("parseAssignExpression"))
+	}
+	exp := &ast.AssignExpression{
+		Token:    p.currToken,
+		Target:   target,
+		Operator: p.currToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.errorf(p.currToken.Pos, "Cannot assign to %s", target.String())
+		return nil
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+
+	return exp
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.BooleanLiteral{
 		Token: p.currToken,
@@ -339,6 +560,60 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	return block
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	if config.Debug != nil && *config.Debug {
+		defer untrace(trace("parseTryExpression"))
+	}
+	expression := &ast.TryExpression{Token: p.currToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		expression.Parameter = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errorf(expression.Token.Pos, "Missing catch or finally after try")
+		return nil
+	}
+
+	return expression
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.currToken}
 
@@ -352,11 +627,21 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Body = p.parseBlockStatement()
+	lit.Body = p.parseFunctionBody()
 
 	return lit
 }
 
+// parseFunctionBody parses the body of a function or macro literal. The loops
+// around the literal cannot be broken from inside its body.
+func (p *Parser) parseFunctionBody() *ast.BlockStatement {
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
+
+	return p.parseBlockStatement()
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
@@ -395,6 +680,39 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.currToken}
+	str.Parts = append(str.Parts, p.parseStringLiteral())
+
+	for {
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if p.peekTokenIs(token.STRING_MIDDLE) {
+			p.nextToken()
+		} else if !p.expectPeek(token.STRING_END) {
+			return nil
+		}
+		str.Parts = append(str.Parts, p.parseStringLiteral())
+
+		if p.currTokenIs(token.STRING_END) {
+			return str
+		}
+	}
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	exp := &ast.ImportExpression{Token: p.currToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	exp.Path = p.currToken.Literal
+
+	return exp
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
@@ -439,9 +757,21 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseDotExpression turns x.name into the index expression x["name"].
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Index = &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.currToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
@@ -454,7 +784,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashLiteralPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -468,6 +798,24 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	return hash
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.currToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseFunctionBody()
+
+	return lit
+}
+
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
@@ -494,18 +842,20 @@ func (p *Parser) expectPeek(tt token.TokenType) bool {
 	return false
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns the precedence tt has as an infix operator, or LOWEST
+// when tt is not one.
+func Precedence(tt token.TokenType) int {
+	if p, ok := precedences[tt]; ok {
 		return p
 	}
 
 	return LOWEST
 }
 
-func (p *Parser) currPrecedence() int {
-	if p, ok := precedences[p.currToken.Type]; ok {
-		return p
-	}
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
 
-	return LOWEST
+func (p *Parser) currPrecedence() int {
+	return Precedence(p.currToken.Type)
 }
@@ -147,6 +147,43 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"1.5e-3;", 0.0015},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Float Literal Test %d, Input %s", i, c.input), func(t *testing.T) {
+			l := lexer.NewLexer(c.input)
+			p := NewParser(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Statements) != 1 {
+				t.Fatalf("Not enough statements in program, got %d want 1", len(program.Statements))
+			}
+
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("Program statement is not an expression, got %T", program.Statements[0])
+			}
+
+			literal, ok := stmt.Expression.(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("Statement is not a float literal, got %T", stmt.Expression)
+			}
+			if literal.Value != c.expected {
+				t.Errorf("Incorrect literal value, got %g want %g", literal.Value, c.expected)
+			}
+		})
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	cases := []struct {
 		input    string
@@ -219,6 +256,8 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"true && false", true, "&&", false},
+		{"foobar || barfoo", "foobar", "||", "barfoo"},
 	}
 
 	for i, c := range cases {
@@ -276,7 +315,21 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"-a.b.c * d", "((-((a[b])[c])) * d)"},
+		{"m.f(x).y", "((m[f])(x)[y])"},
 		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"x = 5", "(x = 5)"},
+		{"x = y = 5 + 5", "(x = (y = (5 + 5)))"},
+		{"x += a == b", "(x += (a == b))"},
+		{"a[1] *= 2 * 3", "((a[1]) *= (2 * 3))"},
+		{"x -= f(y /= 2)", "(x -= f((y /= 2)))"},
+		{"a /* b */ + // c\n d / e", "(a + (d / e))"},
+		{`"n: ${a + b * c}!" + d`, "(n: ${(a + (b * c))}! + d)"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a && b && c", "((a && b) && c)"},
+		{"a == b && c < d || !e", "(((a == b) && (c < d)) || (!e))"},
+		{"x = a || b", "(x = (a || b))"},
 	}
 
 	for i, c := range cases {
@@ -638,6 +691,37 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	input := `"Hello ${name}, you have ${len(items)} items${"!"}";`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("Expression is not an interpolated string, got %T (%+v)",
+			stmt.Expression, stmt.Expression)
+	}
+
+	expected := []string{"Hello ", "name", ", you have ", "len(items)", " items", "!", ""}
+	if len(str.Parts) != len(expected) {
+		t.Fatalf("Incorrect amount of parts, got %d want %d", len(str.Parts), len(expected))
+	}
+
+	for i, part := range str.Parts {
+		if _, ok := part.(*ast.StringLiteral); i%2 == 0 && !ok {
+			t.Errorf("Part %d is not a string literal, got %T", i, part)
+		}
+
+		if part.String() != expected[i] {
+			t.Errorf("Incorrect part %d, got %q want %q", i, part.String(), expected[i])
+		}
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world!";`
 
@@ -755,7 +839,8 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 			len(hash.Pairs), len(expected))
 	}
 
-	for k, v := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		k, v := pair.Key, pair.Value
 		literal, ok := k.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("Key is not a string literal, got %T (%+v)", k, k)
@@ -792,7 +877,8 @@ func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
 			len(hash.Pairs), len(expected))
 	}
 
-	for k, v := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		k, v := pair.Key, pair.Value
 		boolean, ok := k.(*ast.BooleanLiteral)
 		if !ok {
 			t.Errorf("Key is not a boolean literal, got %T (%+v)", k, k)
@@ -830,7 +916,8 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 			len(hash.Pairs), len(expected))
 	}
 
-	for k, v := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		k, v := pair.Key, pair.Value
 		integer, ok := k.(*ast.IntegerLiteral)
 		if !ok {
 			t.Errorf("Key is not an integer literal, got %T (%+v)", k, k)
@@ -875,7 +962,8 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for k, v := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		k, v := pair.Key, pair.Value
 		literal, ok := k.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("Key is not a string literal, got %T (%+v)", k, k)
@@ -1008,3 +1096,326 @@ func checkParserErrors(t *testing.T, p *Parser) {
 
 	return
 }
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Incorrect amount of program statements, got %d want %d",
+			len(program.Statements), 1)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statement is not an expression statement, got %T",
+			program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("Expression in statement is not a macro literal, got %T",
+			stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Incorrect amount of macro parameters, got %d want %d",
+			len(macro.Parameters), 2)
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("Incorrect amount of statements in macro body, got %d want %d",
+			len(macro.Body.Statements), 1)
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statement in macro body is not an expression statement, got %T",
+			macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestParserErrorPositions(t *testing.T) {
+	cases := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let = 5;", "script.mk:1:5: Peeking returned an incorrect token, got = want IDENT"},
+		{"let x = 5;\nlet y 10;", "script.mk:2:7: Peeking returned an incorrect token, got INT want ="},
+		{"let x = 5;\n  ;", "script.mk:2:3: No prefix parse function found for ;"},
+		{"1 + 2 = 3", "script.mk:1:7: Cannot assign to (1 + 2)"},
+		{"f() += 1", "script.mk:1:5: Cannot assign to f()"},
+		{"let x = 1;\nbreak;", "script.mk:2:1: Cannot use break outside of a loop"},
+		{"while (true) { fn() { continue } }", "script.mk:1:23: Cannot use continue outside of a loop"},
+		{"for (1 in x) { }", "script.mk:1:6: Peeking returned an incorrect token, got INT want IDENT"},
+		{"try { 1 }", "script.mk:1:1: Missing catch or finally after try"},
+		{"fn() { export let x = 1; }", "script.mk:1:8: Cannot use export outside of the top level"},
+		{"export x = 1;", "script.mk:1:8: Peeking returned an incorrect token, got IDENT want LET"},
+		{"import x", "script.mk:1:8: Peeking returned an incorrect token, got IDENT want STRING"},
+		{"a.1", "script.mk:1:3: Peeking returned an incorrect token, got INT want IDENT"},
+		{"try { 1 } catch { 2 }", "script.mk:1:17: Peeking returned an incorrect token, got { want ("},
+		{"let x = 1;\n/* a /* b */", "script.mk:2:1: unterminated comment"},
+		{`let s = "a\q";`, `script.mk:1:11: invalid escape sequence \q`},
+		{`"a ${}"`, "script.mk:1:6: No prefix parse function found for STRING_END"},
+		{`"a ${b c}"`, "script.mk:1:8: Peeking returned an incorrect token, got IDENT want STRING_END"},
+		{"let s = `a;\n", "script.mk:1:9: unterminated raw string"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Parser Error Position Test %d", i), func(t *testing.T) {
+			l := lexer.NewLexerWithFilename("script.mk", c.input)
+			p := NewParser(l)
+			p.ParseProgram()
+
+			errors := p.Errors()
+			if len(errors) == 0 {
+				t.Fatalf("Parser did not report any errors")
+			}
+
+			if errors[0] != c.expectedError {
+				t.Errorf("Incorrect parser error, got %q want %q", errors[0], c.expectedError)
+			}
+		})
+	}
+}
+
+func TestImportExpression(t *testing.T) {
+	input := `let lib = import "path/to/lib";`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Incorrect amount of program statements, got %d want %d",
+			len(program.Statements), 1)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("Statement is not a let statement, got %T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Value.(*ast.ImportExpression)
+	if !ok {
+		t.Fatalf("Expression is not an import expression, got %T", stmt.Value)
+	}
+
+	if exp.Path != "path/to/lib" {
+		t.Errorf("Incorrect import path, got %q want %q", exp.Path, "path/to/lib")
+	}
+}
+
+func TestExportStatement(t *testing.T) {
+	input := `export let add = fn(a, b) { a + b };`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Incorrect amount of program statements, got %d want %d",
+			len(program.Statements), 1)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("Statement is not an export statement, got %T", program.Statements[0])
+	}
+
+	if !testLetStatement(t, stmt.Statement, "add") {
+		return
+	}
+
+	expected := "export let add = fn(a, b) (a + b);"
+	if stmt.String() != expected {
+		t.Errorf("Incorrect export statement, got %q want %q", stmt.String(), expected)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	cases := []struct {
+		input       string
+		parameter   string
+		hasCatch    bool
+		hasFinally  bool
+		expectedStr string
+	}{
+		{"try { x } catch (e) { y }", "e", true, false, "try x catch (e) y"},
+		{"try { x } finally { z }", "", false, true, "try x finally z"},
+		{"try { x } catch (err) { y } finally { z }", "err", true, true, "try x catch (err) y finally z"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Try Expression Test Case %d", i), func(t *testing.T) {
+			l := lexer.NewLexer(c.input)
+			p := NewParser(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			if len(program.Statements) != 1 {
+				t.Fatalf("Incorrect amount of program statements, got %d want %d",
+					len(program.Statements), 1)
+			}
+
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("Statement is not an expression statement, got %T", program.Statements[0])
+			}
+
+			exp, ok := stmt.Expression.(*ast.TryExpression)
+			if !ok {
+				t.Fatalf("Expression is not a try expression, got %T", stmt.Expression)
+			}
+
+			if (exp.Catch != nil) != c.hasCatch {
+				t.Errorf("Incorrect catch block presence, got %t want %t", exp.Catch != nil, c.hasCatch)
+			}
+
+			if c.hasCatch && !testIdentifier(t, exp.Parameter, c.parameter) {
+				return
+			}
+
+			if (exp.Finally != nil) != c.hasFinally {
+				t.Errorf("Incorrect finally block presence, got %t want %t", exp.Finally != nil, c.hasFinally)
+			}
+
+			if exp.String() != c.expectedStr {
+				t.Errorf("Incorrect try expression, got %q want %q", exp.String(), c.expectedStr)
+			}
+		})
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	input := `throw "oops" + x;`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Incorrect amount of program statements, got %d want %d",
+			len(program.Statements), 1)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("Statement is not a throw statement, got %T", program.Statements[0])
+	}
+
+	expected := `throw (oops + x);`
+	if stmt.String() != expected {
+		t.Errorf("Incorrect throw statement, got %q want %q", stmt.String(), expected)
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x += 1; continue; }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Incorrect amount of program statements, got %d want %d",
+			len(program.Statements), 1)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("Statement is not a while statement, got %T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("Incorrect amount of body statements, got %d want %d",
+			len(stmt.Body.Statements), 2)
+	}
+
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("Body statement is not a continue statement, got %T", stmt.Body.Statements[1])
+	}
+
+	expected := "while ((x < 10)) (x += 1)continue;"
+	if stmt.String() != expected {
+		t.Errorf("Incorrect while statement, got %q want %q", stmt.String(), expected)
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (item in [1, 2]) { if (item > 1) { break; } }`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Incorrect amount of program statements, got %d want %d",
+			len(program.Statements), 1)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("Statement is not a for statement, got %T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("Incorrect iterable, got %q want %q", stmt.Iterable.String(), "[1, 2]")
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("Incorrect amount of body statements, got %d want %d",
+			len(stmt.Body.Statements), 1)
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Incorrect amount of program statements, got %d want %d",
+			len(program.Statements), 1)
+	}
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("Statement is not a let statement, got %T", program.Statements[0])
+	}
+
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("Let statement value is not a function literal, got %T", stmt.Value)
+	}
+
+	if function.Name != "myFunction" {
+		t.Errorf("Incorrect function literal name, got %q want %q",
+			function.Name, "myFunction")
+	}
+}
@@ -0,0 +1,55 @@
+package repl
+
+import (
+	"github.com/benja-vq/gonkey/lexer"
+	"github.com/benja-vq/gonkey/token"
+)
+
+// Tokens that cannot end a program, input ending with one of them continues
+// on the next line.
+var continuationTokens = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.AND:      true,
+	token.OR:       true,
+	token.COMMA:    true,
+	token.COLON:    true,
+
+	token.PLUS_ASSIGN:     true,
+	token.MINUS_ASSIGN:    true,
+	token.ASTERISK_ASSIGN: true,
+	token.SLASH_ASSIGN:    true,
+
+	token.DOT:    true,
+	token.IMPORT: true,
+	token.EXPORT: true,
+}
+
+// isIncomplete reports whether input needs more lines to form a program: it
+// has unclosed parentheses, brackets, braces, strings or comments, or it ends
+// with an operator.
+func isIncomplete(input string) bool {
+	l := lexer.NewLexer(input)
+	depth := 0
+	last := token.Token{Type: token.EOF}
+
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth += 1
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth -= 1
+		}
+		last = tok
+	}
+
+	return depth > 0 || continuationTokens[last.Type] || l.Unterminated()
+}
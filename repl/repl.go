@@ -3,43 +3,256 @@ package repl
 import (
 	"bufio"
 	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/compiler"
+	"github.com/benja-vq/gonkey/config"
 	"github.com/benja-vq/gonkey/evaluator"
 	"github.com/benja-vq/gonkey/lexer"
 	"github.com/benja-vq/gonkey/object"
 	"github.com/benja-vq/gonkey/parser"
+	"github.com/benja-vq/gonkey/token"
+	"github.com/benja-vq/gonkey/vm"
 	"io"
+	"os"
+	"strings"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT              = ">> "
+	CONTINUATION_PROMPT = ".. "
+)
+
+const HELP = `Enter Monkey code to evaluate it, unfinished input continues on the next line
+and an empty line submits it as is. Meta-commands:
+  :help            show this help
+  :env             list the global bindings
+  :ast <code>      print the syntax tree of code
+  :tokens <code>   print the tokens of code
+  :load <file>     evaluate the file in the current session
+  :history         list the inputs evaluated in this session
+  :reset           discard every binding and macro
+  :quit            leave the REPL
+`
+
+// session holds the state kept between inputs.
+type session struct {
+	out     io.Writer
+	history []string
+
+	env      *object.Environment
+	macroEnv *object.Environment
+
+	// State of the virtual machine backend
+	constants   []object.Object
+	globals     []object.Object
+	symbolTable *compiler.SymbolTable
+	modules     *object.ModuleCache
+}
+
+func newSession(out io.Writer) *session {
+	s := &session{out: out}
+	s.reset()
+	return s
+}
+
+func (s *session) reset() {
+	s.env = object.NewEnvironment()
+	s.macroEnv = object.NewEnvironment()
+
+	s.constants = []object.Object{}
+	s.globals = make([]object.Object, vm.GlobalsSize)
+	s.symbolTable = compiler.NewSymbolTableWithBuiltins()
+	s.modules = object.NewModuleCache()
+}
 
 func Start(in io.Reader, out io.Writer) {
 
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	s := newSession(out)
+
+	var input strings.Builder
 
 	for {
-		_, _ = fmt.Fprintf(out, PROMPT)
+		if input.Len() == 0 {
+			_, _ = fmt.Fprintf(out, PROMPT)
+		} else {
+			_, _ = fmt.Fprintf(out, CONTINUATION_PROMPT)
+		}
+
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 
 		line := scanner.Text()
-		l := lexer.NewLexer(line)
-		p := parser.NewParser(l)
 
+		if input.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if quit := s.runCommand(strings.TrimSpace(line)); quit {
+				return
+			}
+			continue
+		}
+
+		// An empty line submits unfinished input, letting the parser report
+		// what is missing instead of waiting forever.
+		input.WriteString(line + "\n")
+		if line != "" && isIncomplete(input.String()) {
+			continue
+		}
+
+		src := input.String()
+		input.Reset()
+
+		if strings.TrimSpace(src) == "" {
+			continue
+		}
+
+		s.history = append(s.history, strings.TrimSuffix(src, "\n"))
+		s.eval("", src)
+	}
+}
+
+// eval runs src in the session and prints its result.
+func (s *session) eval(filename, src string) {
+	l := lexer.NewLexerWithFilename(filename, src)
+	p := parser.NewParser(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return
+	}
+
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, errObj := evaluator.ExpandMacros(program, s.macroEnv)
+
+	var evaluated object.Object
+	if errObj != nil {
+		evaluated = errObj
+	} else if config.UseVM() {
+		// A failed compilation must not leave its definitions behind
+		symbolTable := s.symbolTable.Clone()
+		comp := compiler.NewWithState(symbolTable, s.constants)
+		if err := comp.Compile(expanded); err != nil {
+			_, _ = fmt.Fprintf(s.out, "Compilation failed: %s\n", err)
+			return
+		}
+
+		bytecode := comp.Bytecode()
+		s.symbolTable = symbolTable
+		s.constants = bytecode.Constants
+
+		machine := vm.NewWithGlobalsStore(bytecode, s.globals)
+		machine.SetModuleCache(s.modules)
+		if err := machine.Run(); err != nil {
+			_, _ = fmt.Fprintf(s.out, "Executing bytecode failed: %s\n", err)
+			return
+		}
+
+		evaluated = machine.LastPoppedStackElem()
+		// The stack keeps the value a let statement stored, the evaluator
+		// gives none
+		if _, ok := evaluated.(*object.Error); !ok && endsWithLet(program) {
+			evaluated = nil
+		}
+	} else {
+		evaluated = evaluator.Eval(expanded, s.env)
+	}
+
+	if evaluated != nil {
+		_, _ = io.WriteString(s.out, evaluated.Inspect())
+		_, _ = io.WriteString(s.out, "\n")
+	}
+
+	if errObj, ok := evaluated.(*object.Error); ok {
+		_, _ = io.WriteString(s.out, errObj.StackTrace())
+	}
+}
+
+// endsWithLet reports whether the last statement of program is a let
+// statement, exported or not.
+func endsWithLet(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+
+	switch program.Statements[len(program.Statements)-1].(type) {
+	case *ast.LetStatement, *ast.ExportStatement:
+		return true
+	}
+
+	return false
+}
+
+// runCommand executes a meta-command line and reports whether the REPL
+// should exit.
+func (s *session) runCommand(line string) bool {
+	command, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch command {
+	case ":quit", ":q":
+		return true
+	case ":help":
+		_, _ = io.WriteString(s.out, HELP)
+	case ":env":
+		s.printEnv()
+	case ":ast":
+		l := lexer.NewLexer(arg)
+		p := parser.NewParser(l)
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
-			continue
+			printParserErrors(s.out, p.Errors())
+			return false
+		}
+		ast.Dump(s.out, program)
+	case ":tokens":
+		l := lexer.NewLexer(arg)
+		l.SetMode(lexer.ScanComments)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			_, _ = fmt.Fprintf(s.out, "%s\t%s\t%q\n", tok.Pos, tok.Type, tok.Literal)
 		}
+	case ":load":
+		if arg == "" {
+			_, _ = io.WriteString(s.out, "Usage: :load <file>\n")
+			return false
+		}
+		src, err := os.ReadFile(arg)
+		if err != nil {
+			_, _ = fmt.Fprintf(s.out, "Could not load file: %s\n", err)
+			return false
+		}
+		s.eval(arg, string(src))
+	case ":history":
+		for i, input := range s.history {
+			_, _ = fmt.Fprintf(s.out, "%4d  %s\n", i+1, input)
+		}
+	case ":reset":
+		s.reset()
+	default:
+		_, _ = fmt.Fprintf(s.out, "Unknown command %s, type :help for a list of commands\n", command)
+	}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			_, _ = io.WriteString(out, evaluated.Inspect())
-			_, _ = io.WriteString(out, "\n")
+	return false
+}
+
+func (s *session) printEnv() {
+	if config.UseVM() {
+		for _, symbol := range s.symbolTable.Symbols() {
+			if value := s.globals[symbol.Index]; value != nil {
+				_, _ = fmt.Fprintf(s.out, "%s = %s\n", symbol.Name, value.Inspect())
+			}
 		}
+	} else {
+		for _, name := range s.env.Names() {
+			value, _ := s.env.Get(name)
+			_, _ = fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
+		}
+	}
 
+	for _, name := range s.macroEnv.Names() {
+		value, _ := s.macroEnv.Get(name)
+		_, _ = fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
 	}
 }
 
@@ -0,0 +1,131 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/benja-vq/gonkey/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n  x + y\n}", false},
+		{"[1, 2,", true},
+		{"{\"a\": 1}", false},
+		{"let x = 5 +", true},
+		{"let x =", true},
+		{"add(1,\n 2", true},
+		{"}", false},
+		{"x +=", true},
+		{"x /* a\n /* b */", true},
+		{"x /* a\n /* b */ */", false},
+		{"x = // c", true},
+		{"let s = `a\n", true},
+		{"let s = \"a\\\"", true},
+		{"let s = \"\\q\"", false},
+		{"let s = \"a ${b", true},
+		{"let s = \"a ${f(fn() {", true},
+		{"let s = \"a ${b}\"", false},
+		{"a &&", true},
+		{"a || b", false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Incomplete Input Test Case %d", i), func(t *testing.T) {
+			if isIncomplete(c.input) != c.expected {
+				t.Errorf("Incorrect result for %q, got %t want %t",
+					c.input, isIncomplete(c.input), c.expected)
+			}
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.mk")
+	if err := os.WriteFile(path, []byte("let double = fn(x) { x * 2 };"), 0o644); err != nil {
+		t.Fatalf("Could not write file: %s", err)
+	}
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2\n", ">> 3\n>> "},
+		{"let add = fn(x, y) {\n  x + y\n};\nadd(1, 2)\n", ">> .. .. >> 3\n>> "},
+		{"[1,\n\n", ">> .. " + MonkeyFace + "The monkey ran into some parsing errors!\n Parser errors:\n" +
+			"\t3:1: No prefix parse function found for \n\t3:2: Peeking returned an incorrect token, got  want ]\n>> "},
+		{"let a = 1;\n:env\n", ">> >> a = 1\n>> "},
+		{"let a = 1;\n:reset\n:env\na\n", ">> >> >> >> ERROR: 1:1: identifier not found: a\n>> "},
+		{"let f = fn(x) { x + true };\nf(1)\n",
+			">> >> ERROR: 1:19: type mismatch: INTEGER + BOOLEAN\n\nstack trace:\nf(1)\n\t1:2\n>> "},
+		{":tokens let x\n", ">> 1:1\tLET\t\"let\"\n1:5\tIDENT\t\"x\"\n>> "},
+		{":tokens x // c\n", ">> 1:1\tIDENT\t\"x\"\n1:3\tCOMMENT\t\"// c\"\n>> "},
+		{":ast -1\n", ">> Program @ 1:1\n  Statements[0]: ExpressionStatement @ 1:1\n" +
+			"    Expression: PrefixExpression (Operator=-) @ 1:1\n" +
+			"      Right: IntegerLiteral (Value=1) @ 1:2\n>> "},
+		{":load " + path + "\ndouble(4)\n", ">> >> 8\n>> "},
+		{"1\n2\n:history\n", ">> 1\n>> 2\n>>    1  1\n   2  2\n>> "},
+		{":quit\n1\n", ">> "},
+		{":nope\n", ">> Unknown command :nope, type :help for a list of commands\n>> "},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("REPL Test Case %d", i), func(t *testing.T) {
+			var out bytes.Buffer
+			Start(strings.NewReader(c.input), &out)
+
+			if out.String() != c.expected {
+				t.Errorf("Incorrect REPL output, got %q want %q", out.String(), c.expected)
+			}
+		})
+	}
+}
+
+func TestStartBackends(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\nx\n", ">> >> 1\n>> "},
+		{"1; let y = 2;\n", ">> >> "},
+		{"export let z = 3;\n", ">> >> "},
+		{"let x = 1 + true;\n", ">> ERROR: 1:11: type mismatch: INTEGER + BOOLEAN\n>> "},
+	}
+
+	for _, engine := range []string{config.EngineEval, config.EngineVM} {
+		config.Engine = &engine
+
+		for i, c := range cases {
+			t.Run(fmt.Sprintf("REPL Backend Test Case %d (%s)", i, engine), func(t *testing.T) {
+				var out bytes.Buffer
+				Start(strings.NewReader(c.input), &out)
+
+				if out.String() != c.expected {
+					t.Errorf("Incorrect REPL output, got %q want %q", out.String(), c.expected)
+				}
+			})
+		}
+	}
+
+	vmEngine := config.EngineVM
+	config.Engine = &vmEngine
+
+	var out bytes.Buffer
+	Start(strings.NewReader("let g = fn() { y };\ng()\n"), &out)
+
+	expected := ">> Compilation failed: 1:16: identifier not found: y\n" +
+		">> Compilation failed: 1:1: identifier not found: g\n>> "
+	if out.String() != expected {
+		t.Errorf("Incorrect REPL output after a failed compilation, got %q want %q", out.String(), expected)
+	}
+
+	config.Engine = nil
+}
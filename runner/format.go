@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/benja-vq/gonkey/format"
+	"io"
+	"os"
+)
+
+// FormatFiles formats the programs at paths to out, or back into their files
+// when write is set, leaving the files already formatted untouched. Without
+// paths, the program read from in is formatted to out. Parser and I/O errors
+// are written to errOut and do not stop the other files from being formatted.
+// The returned value is the exit code for the process.
+func FormatFiles(paths []string, write bool, in io.Reader, out, errOut io.Writer) int {
+	if len(paths) == 0 {
+		if write {
+			_, _ = io.WriteString(errOut, "gonkey: cannot use -w with standard input\n")
+			return ExitUsage
+		}
+
+		src, err := io.ReadAll(in)
+		if err != nil {
+			_, _ = fmt.Fprintf(errOut, "gonkey: %s\n", err)
+			return ExitError
+		}
+
+		return formatSource("<stdin>", src, out, errOut)
+	}
+
+	code := ExitOK
+	for _, path := range paths {
+		if formatFile(path, write, out, errOut) != ExitOK {
+			code = ExitError
+		}
+	}
+
+	return code
+}
+
+func formatFile(path string, write bool, out, errOut io.Writer) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "gonkey: %s\n", err)
+		return ExitError
+	}
+
+	if !write {
+		return formatSource(path, src, out, errOut)
+	}
+
+	formatted, err := format.Source(path, src)
+	if err != nil {
+		_, _ = io.WriteString(errOut, err.Error()+"\n")
+		return ExitError
+	}
+
+	if bytes.Equal(src, formatted) {
+		return ExitOK
+	}
+
+	info, err := os.Stat(path)
+	if err == nil {
+		err = os.WriteFile(path, formatted, info.Mode().Perm())
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "gonkey: %s\n", err)
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+func formatSource(filename string, src []byte, out, errOut io.Writer) int {
+	formatted, err := format.Source(filename, src)
+	if err != nil {
+		_, _ = io.WriteString(errOut, err.Error()+"\n")
+		return ExitError
+	}
+
+	if _, err := out.Write(formatted); err != nil {
+		_, _ = fmt.Fprintf(errOut, "gonkey: %s\n", err)
+		return ExitError
+	}
+
+	return ExitOK
+}
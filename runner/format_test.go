@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFormatFiles(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.mk")
+	bad := filepath.Join(dir, "bad.mk")
+	if err := os.WriteFile(good, []byte("let x=1\nx+2"), 0o644); err != nil {
+		t.Fatalf("Could not write script: %s", err)
+	}
+	if err := os.WriteFile(bad, []byte("let = 1;"), 0o644); err != nil {
+		t.Fatalf("Could not write script: %s", err)
+	}
+
+	var out, errOut bytes.Buffer
+	code := FormatFiles([]string{good, bad}, false, nil, &out, &errOut)
+
+	if code != ExitError {
+		t.Errorf("Incorrect exit code, got %d want %d", code, ExitError)
+	}
+	if out.String() != "let x = 1;\nx + 2;\n" {
+		t.Errorf("Incorrect output, got %q", out.String())
+	}
+	if !strings.HasPrefix(errOut.String(), bad+":1:5: ") {
+		t.Errorf("Incorrect error output, got %q", errOut.String())
+	}
+
+	out.Reset()
+	errOut.Reset()
+	code = FormatFiles([]string{good}, true, nil, &out, &errOut)
+
+	if code != ExitOK || out.Len() != 0 || errOut.Len() != 0 {
+		t.Errorf("Incorrect result writing files, got code %d output %q errors %q", code, out.String(), errOut.String())
+	}
+	if src, _ := os.ReadFile(good); string(src) != "let x = 1;\nx + 2;\n" {
+		t.Errorf("Incorrect formatted file, got %q", src)
+	}
+
+	out.Reset()
+	code = FormatFiles(nil, false, strings.NewReader("puts( 1 )"), &out, &errOut)
+	if code != ExitOK || out.String() != "puts(1);\n" {
+		t.Errorf("Incorrect result formatting stdin, got code %d output %q", code, out.String())
+	}
+
+	if code := FormatFiles(nil, true, strings.NewReader(""), &out, &errOut); code != ExitUsage {
+		t.Errorf("Incorrect exit code for -w without files, got %d want %d", code, ExitUsage)
+	}
+}
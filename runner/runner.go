@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/compiler"
+	"github.com/benja-vq/gonkey/config"
+	"github.com/benja-vq/gonkey/evaluator"
+	"github.com/benja-vq/gonkey/lexer"
+	"github.com/benja-vq/gonkey/module"
+	"github.com/benja-vq/gonkey/object"
+	"github.com/benja-vq/gonkey/parser"
+	"github.com/benja-vq/gonkey/vm"
+	"io"
+	"os"
+)
+
+const (
+	ExitOK    = 0
+	ExitError = 1 // Parser, runtime or I/O error
+	ExitUsage = 2 // Invalid command line
+)
+
+// RunFile reads the script at path and runs it, see Run.
+func RunFile(path string, args []string, errOut io.Writer) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		_, _ = fmt.Fprintf(errOut, "gonkey: %s\n", err)
+		return ExitError
+	}
+
+	return Run(path, string(src), args, errOut)
+}
+
+// Run lexes and parses src as a single program, then evaluates it with args
+// bound to the ARGS array, using the backend selected in config. Parser and
+// runtime errors are written to errOut. The returned value is the exit code
+// for the process.
+func Run(filename, src string, args []string, errOut io.Writer) int {
+	l := lexer.NewLexerWithFilename(filename, src)
+	p := parser.NewParser(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			_, _ = io.WriteString(errOut, msg+"\n")
+		}
+		return ExitError
+	}
+
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, errObj := evaluator.ExpandMacros(program, macroEnv)
+
+	var evaluated object.Object
+	switch {
+	case errObj != nil:
+		evaluated = errObj
+	case config.UseVM():
+		evaluated = runVM(expanded, filename, args)
+	default:
+		env := object.NewEnvironment()
+		env.Set("ARGS", argsArray(args))
+		exit := module.Enter(env.Modules(), filename)
+		evaluated = evaluator.Eval(expanded, env)
+		exit()
+	}
+
+	if errObj, ok := evaluated.(*object.Error); ok {
+		_, _ = io.WriteString(errOut, errObj.Inspect()+"\n"+errObj.StackTrace())
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+// runVM compiles and executes program, read from filename, on the virtual
+// machine. Compilation errors are reported as error objects, like the
+// evaluator does at runtime.
+func runVM(program ast.Node, filename string, args []string) object.Object {
+	symbolTable := compiler.NewSymbolTableWithBuiltins()
+	argsSymbol := symbolTable.Define("ARGS")
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return compileErrorObject(err)
+	}
+
+	globals := make([]object.Object, vm.GlobalsSize)
+	globals[argsSymbol.Index] = argsArray(args)
+
+	modules := object.NewModuleCache()
+	defer module.Enter(modules, filename)()
+
+	machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+	machine.SetModuleCache(modules)
+	if err := machine.Run(); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+func compileErrorObject(err error) *object.Error {
+	if compileErr, ok := err.(*compiler.Error); ok {
+		return &object.Error{Message: compileErr.Message, Pos: compileErr.Pos}
+	}
+
+	return &object.Error{Message: err.Error()}
+}
+
+func argsArray(args []string) *object.Array {
+	elements := make([]object.Object, 0, len(args))
+	for _, arg := range args {
+		elements = append(elements, &object.String{Value: arg})
+	}
+
+	return &object.Array{Elements: elements}
+}
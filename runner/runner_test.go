@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/benja-vq/gonkey/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		input          string
+		args           []string
+		expectedCode   int
+		expectedErrOut string
+	}{
+		{"let x = 5; x + 5;", nil, ExitOK, ""},
+		{"let x = 5;\nx + true;", nil, ExitError, "ERROR: script.mk:2:3: type mismatch: INTEGER + BOOLEAN\n"},
+		{"let 5;", nil, ExitError, "script.mk:1:5: Peeking returned an incorrect token, got INT want IDENT\n"},
+		{`if (len(ARGS) == 2) { ARGS[1] } else { 1 + true }`, []string{"a", "b"}, ExitOK, ""},
+		{`if (len(ARGS[0] + ARGS[1]) == 2) { ok } else { 1 }`, []string{"a", "b"}, ExitError,
+			"ERROR: script.mk:1:36: identifier not found: ok\n"},
+		{"let f = fn(x, s) { x + true };\nlet g = fn() { f(1, \"a\") };\ng();", nil, ExitError,
+			"ERROR: script.mk:1:22: type mismatch: INTEGER + BOOLEAN\n\nstack trace:\n" +
+				"f(1, \"a\")\n\tscript.mk:2:17\ng()\n\tscript.mk:3:2\n"},
+	}
+
+	for _, engine := range []string{config.EngineEval, config.EngineVM} {
+		config.Engine = &engine
+
+		for i, c := range cases {
+			t.Run(fmt.Sprintf("Run Test Case %d (%s)", i, engine), func(t *testing.T) {
+				var errOut bytes.Buffer
+
+				code := Run("script.mk", c.input, c.args, &errOut)
+
+				if code != c.expectedCode {
+					t.Errorf("Incorrect exit code, got %d want %d", code, c.expectedCode)
+				}
+
+				if errOut.String() != c.expectedErrOut {
+					t.Errorf("Incorrect error output, got %q want %q",
+						errOut.String(), c.expectedErrOut)
+				}
+			})
+		}
+	}
+
+	config.Engine = nil
+}
+
+func TestRunTraceDepth(t *testing.T) {
+	input := "let f = fn(n) { if (n == 0) { n + true } else { 1 + f(n - 1) } }; f(3);"
+	expected := "ERROR: script.mk:1:33: type mismatch: INTEGER + BOOLEAN\n\nstack trace:\n" +
+		"f(0)\n\tscript.mk:1:54\nf(1)\n\tscript.mk:1:54\n...2 additional frames elided...\n"
+
+	depth := 2
+	config.TraceDepth = &depth
+
+	for _, engine := range []string{config.EngineEval, config.EngineVM} {
+		config.Engine = &engine
+
+		var errOut bytes.Buffer
+		Run("script.mk", input, nil, &errOut)
+
+		if errOut.String() != expected {
+			t.Errorf("Incorrect error output (%s), got %q want %q", engine, errOut.String(), expected)
+		}
+	}
+
+	config.Engine = nil
+	config.TraceDepth = nil
+}
+
+func TestRunCallbackTrace(t *testing.T) {
+	input := "let g = fn(x) { x + true };\nlet h = fn(xs) { map(xs, g) };\nh([1]);"
+	expected := "ERROR: script.mk:1:19: type mismatch: INTEGER + BOOLEAN\n\nstack trace:\n" +
+		"g(1)\n\tscript.mk:2:21\nh([1])\n\tscript.mk:3:2\n"
+
+	for _, engine := range []string{config.EngineEval, config.EngineVM} {
+		config.Engine = &engine
+
+		var errOut bytes.Buffer
+		Run("script.mk", input, nil, &errOut)
+
+		if errOut.String() != expected {
+			t.Errorf("Incorrect error output (%s), got %q want %q", engine, errOut.String(), expected)
+		}
+	}
+
+	config.Engine = nil
+}
+
+func TestRunFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	if err := os.WriteFile(path, []byte("let x = 1;\nx + y;"), 0o644); err != nil {
+		t.Fatalf("Could not write script: %s", err)
+	}
+
+	var errOut bytes.Buffer
+	code := RunFile(path, nil, &errOut)
+
+	if code != ExitError {
+		t.Errorf("Incorrect exit code, got %d want %d", code, ExitError)
+	}
+
+	expected := "ERROR: " + path + ":2:5: identifier not found: y\n"
+	if errOut.String() != expected {
+		t.Errorf("Incorrect error output, got %q want %q", errOut.String(), expected)
+	}
+
+	errOut.Reset()
+	code = RunFile(filepath.Join(t.TempDir(), "missing.mk"), nil, &errOut)
+	if code != ExitError {
+		t.Errorf("Incorrect exit code for missing file, got %d want %d", code, ExitError)
+	}
+}
+
+func TestRunFileImportedBack(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.mk": "export let v = 1;\nlet b = import \"b\";",
+		"b.mk": "let a = import \"a\";",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatalf("Could not write %s: %s", name, err)
+		}
+	}
+	a, b := filepath.Join(dir, "a.mk"), filepath.Join(dir, "b.mk")
+	expected := fmt.Sprintf("ERROR: %s:1:9: import cycle: %s -> %s -> %s\n", b, a, b, a)
+
+	for _, engine := range []string{config.EngineEval, config.EngineVM} {
+		config.Engine = &engine
+
+		var errOut bytes.Buffer
+		code := RunFile(a, nil, &errOut)
+
+		if code != ExitError {
+			t.Errorf("Incorrect exit code (%s), got %d want %d", engine, code, ExitError)
+		}
+
+		if errOut.String() != expected {
+			t.Errorf("Incorrect error output (%s), got %q want %q", engine, errOut.String(), expected)
+		}
+	}
+
+	config.Engine = nil
+}
@@ -1,10 +1,39 @@
 package token
 
+import "fmt"
+
 type TokenType int
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+}
+
+// Position describes where a token starts in the source. Line and Column are
+// 1-based, Offset is the 0-based byte offset into the input.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position was produced by the lexer.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns the position as file:line:col, omitting the file name when
+// unknown and returning "-" for invalid positions.
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
 }
 
 var KEYWORDS = map[string]TokenType{
@@ -15,6 +44,21 @@ var KEYWORDS = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"macro":  MACRO,
+
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+
+	"throw":   THROW,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+
+	"import": IMPORT,
+	"export": EXPORT,
 }
 
 // LookupIdent Figure out if the received identifier is a keyword or not
@@ -96,6 +140,54 @@ func (tt TokenType) Literal() (lit string) {
 		lit = "]"
 	case 30:
 		lit = ":"
+	case 31:
+		lit = "MACRO"
+	case 32:
+		lit = "FLOAT"
+	case 33:
+		lit = "+="
+	case 34:
+		lit = "-="
+	case 35:
+		lit = "*="
+	case 36:
+		lit = "/="
+	case 37:
+		lit = "WHILE"
+	case 38:
+		lit = "FOR"
+	case 39:
+		lit = "IN"
+	case 40:
+		lit = "BREAK"
+	case 41:
+		lit = "CONTINUE"
+	case 42:
+		lit = "THROW"
+	case 43:
+		lit = "TRY"
+	case 44:
+		lit = "CATCH"
+	case 45:
+		lit = "FINALLY"
+	case 46:
+		lit = "IMPORT"
+	case 47:
+		lit = "EXPORT"
+	case 48:
+		lit = "."
+	case 49:
+		lit = "COMMENT"
+	case 50:
+		lit = "STRING_START"
+	case 51:
+		lit = "STRING_MIDDLE"
+	case 52:
+		lit = "STRING_END"
+	case 53:
+		lit = "&&"
+	case 54:
+		lit = "||"
 	}
 	return lit
 }
@@ -141,4 +233,40 @@ const (
 	LBRACKET
 	RBRACKET
 	COLON
+
+	MACRO
+
+	FLOAT
+
+	PLUS_ASSIGN
+	MINUS_ASSIGN
+	ASTERISK_ASSIGN
+	SLASH_ASSIGN
+
+	WHILE
+	FOR
+	IN
+	BREAK
+	CONTINUE
+
+	THROW
+	TRY
+	CATCH
+	FINALLY
+
+	IMPORT
+	EXPORT
+	DOT
+
+	COMMENT // Only reported by lexers scanning comments
+
+	// Parts of an interpolated string "a${x}b${y}c", the START one running
+	// from the opening quote to the first ${, the MIDDLE ones between a } and
+	// the next ${, and the END one from the last } to the closing quote.
+	STRING_START
+	STRING_MIDDLE
+	STRING_END
+
+	AND
+	OR
 )
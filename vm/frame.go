@@ -0,0 +1,20 @@
+package vm
+
+import (
+	"github.com/benja-vq/gonkey/code"
+	"github.com/benja-vq/gonkey/object"
+)
+
+type Frame struct {
+	cl          *object.Closure
+	ip          int // Offset of the instruction being executed
+	basePointer int // Stack pointer before the frame's locals were allocated
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
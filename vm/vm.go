@@ -0,0 +1,763 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/code"
+	"github.com/benja-vq/gonkey/compiler"
+	"github.com/benja-vq/gonkey/config"
+	"github.com/benja-vq/gonkey/evaluator"
+	"github.com/benja-vq/gonkey/module"
+	"github.com/benja-vq/gonkey/object"
+)
+
+const (
+	InitialStackSize = 2048
+	MaxStackSize     = 1 << 22
+	GlobalsSize      = 65536
+	MaxFrames        = 1 << 20
+)
+
+// The VM shares its singletons with the evaluator, builtins return them.
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // Always points to the next free slot, the top of stack is stack[sp-1]
+
+	globals []object.Object
+
+	frames      []*Frame
+	framesIndex int
+
+	handlers []handler // Try expressions being executed, innermost last
+
+	modules *object.ModuleCache // Modules imported by the program
+
+	limiter *object.Limiter // Passed to builtins, programs run on the VM have no limits
+
+	// result is set when execution stops before the end of the program, either
+	// because of a runtime error or a top level return statement.
+	result object.Object
+}
+
+// handler is the catch block of a try expression being executed.
+type handler struct {
+	catchIP     int // Instruction the catch block starts at
+	framesIndex int // Frame the try expression runs in
+	sp          int // Stack pointer when the try expression started
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+	}
+	globals := make([]object.Object, GlobalsSize)
+	mainClosure := &object.Closure{Fn: mainFn, Constants: bytecode.Constants, Globals: globals}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, InitialStackSize),
+		sp:    0,
+
+		globals: globals,
+
+		modules: object.NewModuleCache(),
+		limiter: object.NewLimiter(),
+
+		frames:      []*Frame{mainFrame},
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsStore creates a VM that reads and writes globals in s, so that
+// they survive across several runs as needed by the REPL.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	vm.currentFrame().cl.Globals = s
+	return vm
+}
+
+// SetModuleCache makes the program share the modules imported by the programs
+// using the same cache, such as the previous inputs of a REPL session.
+func (vm *VM) SetModuleCache(modules *object.ModuleCache) {
+	vm.modules = modules
+}
+
+// LastPoppedStackElem returns the value of the last expression statement that
+// was executed, or the error or returned value that stopped the program.
+func (vm *VM) LastPoppedStackElem() object.Object {
+	if vm.result != nil {
+		return vm.result
+	}
+
+	return vm.stack[vm.sp]
+}
+
+// Run executes the bytecode. Monkey runtime errors stop execution and are
+// reported by LastPoppedStackElem, the returned error is reserved for
+// malformed bytecode.
+func (vm *VM) Run() error {
+	return vm.run(0)
+}
+
+// run executes instructions until the frames above floor return, or to the
+// end of the program for a floor of 0. Errors are only caught by the try
+// expressions of those frames, an error they do not catch unwinds them and
+// stops execution.
+func (vm *VM) run(floor int) error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.framesIndex > floor && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip += 1
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		var errObj *object.Error
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			errObj = vm.push(vm.constants[constIndex])
+		case code.OpPop:
+			vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			errObj = vm.executeBinaryOperation(op)
+		case code.OpBang:
+			errObj = vm.pushResult(evaluator.EvalPrefix("!", vm.pop()))
+		case code.OpMinus:
+			errObj = vm.pushResult(evaluator.EvalPrefix("-", vm.pop()))
+		case code.OpTrue:
+			errObj = vm.push(True)
+		case code.OpFalse:
+			errObj = vm.push(False)
+		case code.OpNull:
+			errObj = vm.push(Null)
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			// Globals are declared before their let statement runs
+			if vm.globals[globalIndex] == nil {
+				errObj = newError(object.NAME_ERROR, "variable used before its definition")
+				break
+			}
+			errObj = vm.push(vm.globals[globalIndex])
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			local := vm.stack[frame.basePointer+int(localIndex)]
+			if local == nil {
+				errObj = newError(object.NAME_ERROR, "variable used before its definition")
+				break
+			}
+			errObj = vm.push(local)
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			builtin, _ := evaluator.GetBuiltin(evaluator.BuiltinNames[builtinIndex])
+			errObj = vm.push(builtin)
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			errObj = vm.push(vm.currentFrame().cl.Free[freeIndex])
+		case code.OpCurrentClosure:
+			errObj = vm.push(vm.currentFrame().cl)
+		case code.OpMakeCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			slot := vm.currentFrame().basePointer + int(localIndex)
+			if _, ok := vm.stack[slot].(*object.Cell); !ok {
+				vm.stack[slot] = &object.Cell{Value: vm.stack[slot]}
+			}
+		case code.OpGetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			cell, err := asCell(vm.stack[vm.currentFrame().basePointer+int(localIndex)])
+			if err != nil {
+				errObj = err
+				break
+			}
+			errObj = vm.push(cell.Value)
+		case code.OpSetLocalCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			cell, err := asCell(vm.stack[vm.currentFrame().basePointer+int(localIndex)])
+			if err != nil {
+				errObj = err
+				break
+			}
+			cell.Value = vm.pop()
+		case code.OpGetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			cell, err := asCell(vm.currentFrame().cl.Free[freeIndex])
+			if err != nil {
+				errObj = err
+				break
+			}
+			errObj = vm.push(cell.Value)
+		case code.OpSetFreeCell:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			cell, err := asCell(vm.currentFrame().cl.Free[freeIndex])
+			if err != nil {
+				errObj = err
+				break
+			}
+			cell.Value = vm.pop()
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			errObj = vm.push(array)
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var hash object.Object
+			hash, errObj = vm.buildHash(vm.sp-numElements, vm.sp)
+			if errObj == nil {
+				vm.sp = vm.sp - numElements
+				errObj = vm.push(hash)
+			}
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			str := evaluator.Interpolate(vm.stack[vm.sp-numParts : vm.sp])
+			vm.sp = vm.sp - numParts
+			errObj = vm.push(str)
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			errObj = vm.pushResult(evaluator.EvalIndex(left, index))
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			errObj = vm.pushResult(evaluator.SetIndex(left, index, val))
+		case code.OpDup:
+			n := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip += 1
+			start := vm.sp - n
+			for i := 0; i < n && errObj == nil; i++ {
+				errObj = vm.push(vm.stack[start+i])
+			}
+		case code.OpIter:
+			elements, err := evaluator.IterableElements(vm.pop())
+			if err != nil {
+				errObj = err
+				break
+			}
+			errObj = vm.push(&object.Iterator{Elements: elements})
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			iterator := vm.pop().(*object.Iterator)
+			if el, ok := iterator.Next(); ok {
+				errObj = vm.push(el)
+			} else {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpTry:
+			catchIP := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			vm.handlers = append(vm.handlers, handler{
+				catchIP:     catchIP,
+				framesIndex: vm.framesIndex,
+				sp:          vm.sp,
+			})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpThrow:
+			errObj = evaluator.ThrownError(vm.pop())
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			errObj = vm.executeImport(vm.constants[constIndex].(*object.String).Value, ip)
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			errObj = vm.executeCall(int(numArgs))
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				vm.result = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			errObj = vm.push(returnValue)
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			errObj = vm.push(Null)
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			errObj = vm.pushClosure(int(constIndex), int(numFree))
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+
+		if errObj != nil && !vm.raise(errObj, ip, floor) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// raise unwinds the frames up to the innermost try expression and continues
+// with its catch block, the exception on top of the stack. Without a try
+// expression above floor to catch errObj, the frames above floor are unwound
+// and execution stops with it. errObj is stamped with the source position of
+// the instruction at ip in the current frame when it has none, and records
+// every call it unwinds. raise reports whether execution goes on.
+func (vm *VM) raise(errObj *object.Error, ip int, floor int) bool {
+	if !errObj.Pos.IsValid() {
+		errObj.Pos = vm.currentFrame().cl.Fn.Positions[ip]
+	}
+
+	target := max(floor, 1)
+	caught := len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex > floor
+	var h handler
+	if caught {
+		h = vm.handlers[len(vm.handlers)-1]
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		target = h.framesIndex
+	}
+
+	for vm.framesIndex > target {
+		args := vm.frameArgs(vm.currentFrame())
+		frame := vm.popFrame()
+
+		// The caller's ip is on the operand of its OpCall instruction
+		caller := vm.currentFrame()
+		errObj.PushFrame(object.StackFrame{
+			Function: frame.cl.Fn.Name,
+			Pos:      caller.cl.Fn.Positions[caller.ip-1],
+			Args:     object.SummarizeArgs(args),
+		}, config.MaxTraceDepth())
+	}
+
+	if !caught {
+		vm.result = errObj
+		return false
+	}
+
+	vm.sp = h.sp
+	vm.stack[vm.sp] = &object.Exception{Error: errObj, Callers: vm.callers()}
+	vm.sp += 1
+	vm.currentFrame().ip = h.catchIP - 1
+
+	return true
+}
+
+// callers returns the stack frames of the calls being executed, innermost
+// first.
+func (vm *VM) callers() []object.StackFrame {
+	frames := make([]object.StackFrame, 0, vm.framesIndex-1)
+	for i := vm.framesIndex - 1; i > 0; i -= 1 {
+		frame, caller := vm.frames[i], vm.frames[i-1]
+		frames = append(frames, object.StackFrame{
+			Function: frame.cl.Fn.Name,
+			Pos:      caller.cl.Fn.Positions[caller.ip-1],
+			Args:     object.SummarizeArgs(vm.frameArgs(frame)),
+		})
+	}
+
+	return frames
+}
+
+// executeImport pushes the module path refers to, compiling and running the
+// file on a virtual machine of its own the first time it is imported. The
+// instruction at ip tells which file imports it.
+func (vm *VM) executeImport(path string, ip int) *object.Error {
+	importer := vm.currentFrame().cl.Fn.Positions[ip].Filename
+
+	mod, errObj := module.Import(vm.modules, path, importer, func(program *ast.Program) (map[string]object.Object, *object.Error) {
+		macroEnv := object.NewEnvironment()
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, errObj := evaluator.ExpandMacros(program, macroEnv)
+		if errObj != nil {
+			return nil, errObj
+		}
+
+		symbolTable := compiler.NewSymbolTableWithBuiltins()
+		comp := compiler.NewWithState(symbolTable, []object.Object{})
+		if err := comp.Compile(expanded); err != nil {
+			if compileErr, ok := err.(*compiler.Error); ok {
+				return nil, &object.Error{Message: compileErr.Message, Pos: compileErr.Pos}
+			}
+			return nil, &object.Error{Message: err.Error()}
+		}
+
+		globals := make([]object.Object, GlobalsSize)
+		machine := NewWithGlobalsStore(comp.Bytecode(), globals)
+		machine.modules = vm.modules
+		if err := machine.Run(); err != nil {
+			return nil, &object.Error{Message: err.Error()}
+		}
+		if errObj, ok := machine.result.(*object.Error); ok {
+			return nil, errObj
+		}
+
+		exports := make(map[string]object.Object)
+		for _, name := range module.Exports(expanded.(*ast.Program)) {
+			symbol, _ := symbolTable.Resolve(name)
+			exports[name] = globals[symbol.Index]
+		}
+
+		return exports, nil
+	})
+	if errObj != nil {
+		return errObj
+	}
+
+	return vm.push(mod)
+}
+
+// frameArgs returns the arguments frame was called with, reading them through
+// their cells when parameters were captured.
+func (vm *VM) frameArgs(frame *Frame) []object.Object {
+	args := make([]object.Object, 0, frame.cl.Fn.NumParameters)
+
+	for _, arg := range vm.stack[frame.basePointer : frame.basePointer+frame.cl.Fn.NumParameters] {
+		if cell, ok := arg.(*object.Cell); ok {
+			arg = cell.Value
+		}
+		if arg == nil {
+			arg = Null
+		}
+		args = append(args, arg)
+	}
+
+	return args
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) *object.Error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftInt, leftOk := left.(*object.Integer)
+	rightInt, rightOk := right.(*object.Integer)
+	if leftOk && rightOk {
+		return vm.executeIntegerOperation(op, leftInt.Value, rightInt.Value)
+	}
+
+	return vm.pushResult(evaluator.EvalInfix(binaryOperators[op], left, right))
+}
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+func (vm *VM) executeIntegerOperation(op code.Opcode, left, right int64) *object.Error {
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.Integer{Value: left + right})
+	case code.OpSub:
+		return vm.push(&object.Integer{Value: left - right})
+	case code.OpMul:
+		return vm.push(&object.Integer{Value: left * right})
+	case code.OpDiv:
+		if right == 0 {
+			return newError(object.RUNTIME_ERROR, "division by zero")
+		}
+		return vm.push(&object.Integer{Value: left / right})
+	case code.OpEqual:
+		return vm.push(evaluator.NativeBoolToBooleanObject(left == right))
+	case code.OpNotEqual:
+		return vm.push(evaluator.NativeBoolToBooleanObject(left != right))
+	case code.OpGreaterThan:
+		return vm.push(evaluator.NativeBoolToBooleanObject(left > right))
+	default:
+		return vm.push(evaluator.NativeBoolToBooleanObject(left < right))
+	}
+}
+
+// asCell returns the cell a captured and reassigned variable is stored in. The
+// slot only holds no cell yet when the variable is used before its definition.
+func asCell(obj object.Object) (*object.Cell, *object.Error) {
+	cell, ok := obj.(*object.Cell)
+	if !ok {
+		return nil, newError(object.NAME_ERROR, "variable used before its definition")
+	}
+
+	return cell, nil
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+	copy(elements, vm.stack[startIndex:endIndex])
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, *object.Error) {
+	hash := object.NewHash((endIndex - startIndex) / 2)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := object.AsHashable(key)
+		if !ok {
+			return nil, newError(object.TYPE_ERROR, "%s is not usable as a hash key", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return newError(object.TYPE_ERROR, "%s is not a function", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments, got %d want %d",
+			numArgs, cl.Fn.NumParameters)
+	}
+
+	if vm.framesIndex >= MaxFrames {
+		return newError(object.RUNTIME_ERROR, "stack overflow")
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	vm.pushFrame(frame)
+
+	if err := vm.grow(frame.basePointer + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	// Clear the locals, a slot read before its let statement ran would
+	// otherwise see what a previous call left behind
+	for i := frame.basePointer + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Call(vm.limiter, vm.callFunction, args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = Null
+	}
+
+	return vm.pushResult(result)
+}
+
+// callFunction calls fn with args and returns its result, running the frames
+// of a closure to their end before going on with the current instruction. It
+// is how builtins call the functions they are passed.
+func (vm *VM) callFunction(fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Closure:
+		return vm.callClosureNow(fn, args)
+	case *object.Builtin:
+		if result := fn.Call(vm.limiter, vm.callFunction, args...); result != nil {
+			return result
+		}
+		return Null
+	default:
+		return newError(object.TYPE_ERROR, "%s is not a function", fn.Type())
+	}
+}
+
+func (vm *VM) callClosureNow(cl *object.Closure, args []object.Object) object.Object {
+	sp, floor := vm.sp, vm.framesIndex
+	defer func() { vm.sp = sp }()
+
+	if err := vm.push(cl); err != nil {
+		return err
+	}
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			return err
+		}
+	}
+	if err := vm.callClosure(cl, len(args)); err != nil {
+		return err
+	}
+
+	if err := vm.run(floor); err != nil {
+		return newError(object.RUNTIME_ERROR, "%s", err)
+	}
+
+	if vm.result != nil {
+		result := vm.result
+		vm.result = nil
+		return result
+	}
+
+	return vm.pop()
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) *object.Error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return newError(object.TYPE_ERROR, "not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free, Constants: vm.constants, Globals: vm.globals}
+	return vm.push(closure)
+}
+
+// pushResult pushes obj unless it is an error, which is returned instead.
+func (vm *VM) pushResult(obj object.Object) *object.Error {
+	if errObj, ok := obj.(*object.Error); ok {
+		return errObj
+	}
+
+	return vm.push(obj)
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if err := vm.grow(vm.sp + 1); err != nil {
+		return err
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp += 1
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp -= 1
+	return o
+}
+
+// grow makes sure the stack has room for size elements.
+func (vm *VM) grow(size int) *object.Error {
+	if size <= len(vm.stack) {
+		return nil
+	}
+
+	if size > MaxStackSize {
+		return newError(object.RUNTIME_ERROR, "stack overflow")
+	}
+
+	newSize := len(vm.stack) * 2
+	for newSize < size {
+		newSize *= 2
+	}
+
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+
+	return nil
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex < len(vm.frames) {
+		vm.frames[vm.framesIndex] = f
+	} else {
+		vm.frames = append(vm.frames, f)
+	}
+	vm.framesIndex += 1
+
+	vm.constants = f.cl.Constants
+	vm.globals = f.cl.Globals
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex -= 1
+
+	// The try expressions of the frame cannot catch anything anymore
+	for len(vm.handlers) > 0 && vm.handlers[len(vm.handlers)-1].framesIndex > vm.framesIndex {
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	}
+
+	caller := vm.currentFrame()
+	vm.constants = caller.cl.Constants
+	vm.globals = caller.cl.Globals
+
+	return vm.frames[vm.framesIndex]
+}
+
+func newError(kind string, format string, a ...any) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
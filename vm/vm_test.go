@@ -0,0 +1,369 @@
+package vm
+
+import (
+	"fmt"
+	"github.com/benja-vq/gonkey/ast"
+	"github.com/benja-vq/gonkey/compiler"
+	"github.com/benja-vq/gonkey/evaluator"
+	"github.com/benja-vq/gonkey/lexer"
+	"github.com/benja-vq/gonkey/object"
+	"github.com/benja-vq/gonkey/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// backendCases mirrors the evaluator test inputs. Every one of them must give
+// the same result on the virtual machine as on the tree-walking evaluator.
+var backendCases = []string{
+	// Integers and floats
+	"5", "27", "-5", "-27", "5 + 5 + 5 + 5 - 10", "2 * 2 * 2 * 2 * 2", "-50 + 100 + -50",
+	"5 * 2 + 10", "5 + 2 * 10", "20 + 2 * -10", "50 / 2 * 2 + 10", "2 * (5 + 10)",
+	"3 * 3 * 3 + 10", "3 * (3 * 3) + 10", "(5 + 10 * 2 + 15 / 3) * 2 + -10",
+	"2.5", "-2.5", "1.5e-3", "0.5 + 0.25", "1 + 0.5", "0.5 + 1", "3 * 1.5", "1 / 2.0",
+	"10.0 - 2 * 2", "(1 + 2 + 3) / 4.0", "1 / 0", "let a = 1; a /= 0", "try { 1 / 0 } catch (e) { e }",
+	// Booleans
+	"true", "false", "1 < 2", "1 > 2", "1 < 1", "1 > 1", "1 == 1", "1 != 1", "1 == 2",
+	"1 != 2", "true == true", "false == false", "true == false", "true != false",
+	"false != true", "(1 < 2) == true", "(1 < 2) == false", "(1 > 2) == true",
+	"(1 > 2) == false", "1.5 < 2", "2 > 1.5", "1 == 1.0", "1.0 != 1", "0.1 + 0.2 == 0.3",
+	"2.5 > 2.5",
+	"!true", "!false", "!27", "!!true", "!!false", "!!27",
+	// Conditionals
+	"if (true) { 10 }", "if (false) { 10 }", "if (1) { 10 }", "if (1 < 2) { 10 }",
+	"if (1 > 2) { 10 }", "if (1 > 2) { 10 } else { 20 }", "if (1 < 2) { 10 } else { 20 }",
+	"!(if (false) { 5; })",
+	// Return statements
+	"return 10;", "return 10; 9;", "return 2 * 5; 9;", "9; return 2 * 5; 9;",
+	"if (10 > 1) { if (10 > 1) { return 10; } return 1; }",
+	"let f = fn(x) { return x; x + 10; }; f(10);",
+	"let f = fn(x) { let result = x + 10; return result; return 10; }; f(10);",
+	// Errors
+	"5 + true;", "5 + true; 5;", "-true", "true + false", "5; true + false; 5",
+	"if (10 > 1) { true + false; }",
+	"if (10 > 1) { if (10 > 1) { return true + false; } return 1; }",
+	"foobar", `"Hello" - "World"`, `{"name": "Monkey"}[fn(x) { x }];`,
+	"let x = 5;\nx + true;", "let f = fn() {\n  -true\n};\nf();", "len(1)",
+	// Let statements
+	"let a = 5; a;", "let a = 5 * 5; a;", "let a = 5; let b = a; b;",
+	"let a = 5; let b = a; let c = a + b + 5; c;", "let a = 1; let a = a + 1; a",
+	// Functions and closures
+	"let identity = fn(x) { x; }; identity(5);", "let identity = fn(x) { return x; }; identity(5);",
+	"let double = fn(x) { x * 2; }; double(5);", "let add = fn(x, y) { x + y; }; add(5, 5);",
+	"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", "fn(x) { x; }(5)",
+	"let newAdder = fn(x) { fn(y) { x + y }; }; let addTwo = newAdder(2); addTwo(2);",
+	`let newAdder = fn(a, b) { fn(c) { fn(d) { a + b + c + d } } }; newAdder(1, 2)(3)(8)`,
+	`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`,
+	`let wrapper = fn() { let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1) } }; countDown(5); }; wrapper();`,
+	`let f = fn() { let a = 1; let g = fn() { a + 1 }; g() }; f()`,
+	// Strings
+	`"Hello World!"`, `"Hello" + " " + "World!"`,
+	`"héllo"[1]`, `"héllo"[5]`, `len("héllo")`, `slice("héllo", 1, 3)`, `bytes("é")`,
+	`let s = ""; for (c in "🙈🙉") { s = c + s }; s`,
+	`let name = "Ana"; "Hello ${name}, you have ${len([1, 2])} items"`, `"${1.5} ${[1, "a"]} ${"${true}"}"`,
+	`let f = fn(n) { "n=${n}" }; f(3)`, `"a ${b} c"`,
+	`"a" < "b"`, `"b" > "abc"`, `"a" == "a"`, `"a" != "a"`, `"a" < 1`,
+	`1 && 2`, `first([]) && 2`, `false || "b"`, `0 || 2`, `1 < 2 && 2 < 3 || false`,
+	`let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); n`,
+	`let f = fn(x) { x > 0 && f(x - 1) || x == 0 }; f(5)`, `[1 && [], false || {}]`,
+	`false || 1 + true`, `true && y`,
+	`[1, [2]] == [1, [2]]`, `{"a": 1, "b": 2} == {"b": 2, "a": 1}`, `[1] != [1.0]`, `{"a": [1]} == {"a": [2]}`,
+	`let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b`, `index_of([[1], {"a": 1}], {"a": 1})`,
+	`join(split("a,b", ","), "+")`, `upper(trim(" a "))`, `index_of("héllo", "l")`, `substr("héllo", 1, 3)`,
+	`format("%s=%d", "x", 1)`, `repeat("a", -1)`, `contains("abc", "b")`,
+	// Higher-order builtins
+	`map([1, 2, 3], fn(x) { x * 2 })`, `filter(range(10), fn(x) { x > 6 })`,
+	`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, `reduce([1, 2, 3], fn(acc, x) { acc * x })`,
+	`let total = 0; each([1, 2, 3], fn(x) { total += x }); total`, `map(["a"], upper)`,
+	`sort([3, 1, 2], fn(a, b) { a > b })`, `find([1, 2, 3], fn(x) { x > 1 })`, `all([1, 2], fn(x) { x > 0 })`,
+	`let k = 10; let add = fn(x) { x + k }; map([1, 2], add)`,
+	`map([1, 2], fn(x) { map([x], fn(y) { y + x }) })`,
+	`let fact = fn(n) { if (n < 2) { 1 } else { n * reduce([n - 1], fn(a, b) { fact(b) }, 0) } }; fact(5)`,
+	`let f = fn() { map([1], fn(x) { return x + 1; 0 }) }; f()`,
+	`map([1, true], fn(x) { x + 1 })`, `map([1], fn(x, y) { x })`, `map([1], 1)`,
+	`try { map([1], fn(x) { throw "no" }) } catch (e) { e.message }`,
+	`map([1, 2], fn(x) { try { throw x } catch (e) { e.message * 10 } })`,
+	`let f = fn(xs) { try { each(xs, fn(x) { if (x > 1) { throw "big" } }) } catch (e) { e.message } }; [f([1]), f([1, 2])]`,
+	`let g = fn() { map([1], fn(x) { y }) }; let h = fn() { g() }; try { h() } catch (e) { 1 }; h()`,
+	`sort(range(50), fn(a, b) { if (a == 25) { throw "cmp" } else { a < b } })`,
+	// Builtins
+	`len("")`, `len("four")`, `len("hello world")`, `len(1)`, `len("one", "two")`,
+	`len([1, 2, 3])`, `len([])`, `first([1, 2, 3])`, `first([27])`, `first([])`, `first(1)`,
+	`first("32")`, "first()", "first([1, 2], 2)", `last([1, 2, 3])`, `last([27])`, `last([])`,
+	`last(1)`, `last("32")`, "last()", "last([1, 2], 2)", `rest([1, 2, 3])`, `rest([])`,
+	`rest(1)`, `rest()`, `push([], 1)`, `push([3], 5)`, `push(1, 1)`, `push()`,
+	`!first([])`,
+	// Arrays
+	"[1, 2 * 2, 3 + 3]", "[1, 2, 3][0]", "[1, 2, 3][1]", "[1, 2, 3][2]", "let i = 0; [1][i];",
+	"[1, 2, 3][1 + 1];", "let myArray = [1, 2, 3]; myArray[2]",
+	"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];",
+	"let myArray = [1, 2 ,3]; let i = myArray[0]; myArray[i]", "[1, 2, 3][3]", "[1, 2, 3][-1]",
+	// Hashes
+	`let two = "two"; {"one": 10 - 9, two: 1 + 1, "thr" + "ee": 6 / 2, 4: 4, true: 5, false: 6}`,
+	`{"foo": 5}["foo"]`, `{"foo": 5}["bar"]`, `let key = "foo"; {"foo": 5}[key]`, `{}["foo"]`,
+	`{5: 5}[5]`, `{true: 5}[true]`, `{false: 5}[false]`, `{[1]: 2}`,
+	`{"b": 1, "a": 2, 3: 3}`, `let h = {"b": 1}; h["a"] = 2; h["b"] = 3; [keys(h), values(h), items(h)]`,
+	`let h = {"a": 1}; [has(h, "a"), get(h, "b", 0), delete(h, "a"), len(h)]`,
+	`let h = {1: "a", 1.0: "b", [2]: "c"}; [h, h[1.0], h[[2.0]], has(h, 1.0)]`,
+	`merge({"a": 1, "b": 2}, {"c": 3, "a": 4})`, `get({}, [1])`,
+	`{[1, "a"]: 5}[[1, "a"]]`, `let k = [1]; let h = {k: 1}; k[0] = 2; [h[[1]], h[k], keys(h)]`,
+	`{[1, fn(x) { x }]: 1}`, `unique([[1], [1], [2]])`,
+	// Assignment
+	"let a = 5; a = 10; a;", "let a = 5; a = a + 1;", "let a = 1; let b = 2; a = b = 3; a + b;",
+	"let a = 5; a += 2; a;", "let a = 5; a -= 2; a;", "let a = 5; a *= 2; a;", "let a = 5; a /= 2; a;",
+	"let a = 1.5; a *= 2; a;", `let s = "a"; s += "b"; s;`,
+	"let a = 5; let f = fn() { a = 10 }; f(); a;", "let a = 5; let f = fn() { let a = 1; a = 10 }; f(); a;",
+	"let f = fn(x) { x += 1; x }; f(1);", "let f = fn() { let x = 1; x = x * 3; x }; f();",
+	"let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next();",
+	"let f = fn(n) { let inc = fn() { n = n + 1 }; inc(); inc(); n }; f(5);",
+	"let f = fn() { let v = 0; let g = fn() { fn() { v += 5 } }; g()(); g()(); v }; f();",
+	"let f = fn() { let v = 1; let g = fn() { v }; v = 2; g() }; f();",
+	"let a = [1, 2, 3]; a[1] = 5; a;", "let a = [1, 2, 3]; a[2] += 5; a;",
+	"let a = [1, 2, 3]; let b = a; b[0] = 7; a[0];", `let h = {"a": 1}; h["a"] = 2; h["a"];`,
+	`let h = {"a": 1}; h["b"] = 3; h["a"] + h["b"];`, `let h = {"a": 3}; h["a"] *= 3; h;`,
+	`let m = {"a": [1]}; m["a"][0] -= 2; m["a"][0];`, "foobar += 1", "[1, 2][2] = 3",
+	"let a = 1; a[0] = 3", `{"a": 1}[fn(x) { x }] = 2`, "let a = 1; a += true;",
+	// Loops
+	"let i = 0; while (i < 5) { i += 1; }; i;", "let i = 0; while (i < 5) { i += 1; if (i == 3) { break; } }; i;",
+	"let i = 0; let s = 0; while (i < 5) { i += 1; if (i == 2) { continue; } s += i; }; s;",
+	"let f = fn() { while (true) { return 7; } }; f();", "while (false) { 1 }",
+	"let f = fn() { let n = 0; while (n < 3) { n += 1 } }; f();",
+	"let s = 0; for (x in [1, 2, 3]) { s += x; }; s;", `let out = []; for (c in "abc") { out = push(out, c); }; out;`,
+	`let out = []; for (k in {"b": 2, "a": 1}) { out = push(out, k); }; out;`,
+	"let s = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } s += x; }; s;",
+	"let a = [1, 2]; for (x in a) { a = push(a, x); }; a;", "for (x in []) { x }", "for (x in 5) { x }",
+	"let out = []; for (x in [1, 2]) { for (y in [3, 4]) { if (y == 4) { break; } out = push(out, x * y); } }; out;",
+	"let f = fn(a) { for (x in a) { if (x > 1) { return x; } }; 0 }; f([1, 5, 9]);",
+	// Exceptions
+	"try { 1 } catch (e) { 2 }", "try { 1 + true } catch (e) { 2 }", `try { throw "oops" } catch (e) { e["message"] }`,
+	`try { throw "oops" } catch (e) { e["kind"] }`, `try { throw 42 } catch (e) { e["message"] }`,
+	`try { throw {"kind": "ValueError", "message": "bad"} } catch (e) { [e["kind"], e["message"]] }`,
+	"try { len(1) } catch (e) { e }", `try { {}[fn() {}] } catch (e) { e["kind"] }`,
+	`try { [1][1] = 2 } catch (e) { e["kind"] }`, `try { throw "oops" } catch (e) { e }; e["message"]`,
+	"try { } catch (e) { }", "try { let a = 1; } finally { }", "let x = try { 10 } catch (e) { 20 }; x",
+	"let log = []; try { 1 } finally { log = push(log, 2) }; log",
+	"let log = []; try { try { 1 + true } finally { log = push(log, 2) } } catch (e) { log }",
+	`let log = []; try { throw "a" } catch (e) { log = push(log, 1) } finally { log = push(log, 2) }; log`,
+	"let f = fn() { try { return 1 } finally { return 2 } }; f()",
+	"let n = 0; let f = fn() { try { return 1 } finally { n = 5 } }; f() + n",
+	`try { try { throw "inner" } catch (e) { throw e } } catch (e) { e["message"] }`,
+	`try { throw "a" } catch (e) { throw "b" }`, `try { 1 } finally { throw "c" }`, `throw "top"`,
+	`let f = fn() { throw "deep" }; let g = fn() { f() }; try { g() } catch (e) { e["stack"] }`,
+	`let f = fn(n) { if (n == 0) { throw "x" } 1 + f(n - 1) }; try { f(3) } catch (e) { e["stack"] }`,
+	`let f = fn() { let g = fn() { throw "x" }; try { g() } catch (e) { 1 } }; [f(), f()]`,
+	`let f = fn() { try { return 1 } catch (e) { 2 } }; f(); try { throw "after" } catch (e) { e["message"] }`,
+	`let out = []; for (x in [1, 2, 3]) { try { if (x == 2) { continue } if (x == 3) { break } out = push(out, x) } finally { out = push(out, -x) } }; out`,
+	`let f = fn() { let r = 0; try { throw "x" } catch (e) { let g = fn() { e["message"] }; r = g() }; r }; f()`,
+	`let f = fn() { throw "x" }; let g = fn() { f() }; g()`,
+	`let f = fn() { try { throw "inside" } finally { 1 } }; try { f() } catch (e) { e["stack"] }`,
+	`let f = fn() { try { 1 + "a" } catch (e) { e["stack"] } }; let g = fn() { let r = f(); r }; g()`,
+	`let f = fn() { throw "deep" }; let g = fn(x) { try { f() } catch (e) { e["stack"] } }; g(1)`,
+	"let f = fn() { let fs = []; for (x in [1, 2]) { fs = push(fs, fn() { x }); }; fs[0]() }; f();",
+	"let f = fn() { let n = 0; let inc = fn() { n += 1 }; while (n < 4) { inc(); }; n }; f();",
+	// Globals defined after the functions using them, and locals shadowing them
+	"let f = fn() { g() }; let g = fn() { 1 }; f()",
+	"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(11)",
+	"let a = 1; let f = fn() { let a = a + 1; a }; [f(), a]",
+	"let f = fn() { let x = 1; let g = fn() { let x = x * 10; x }; g() + x }; f()",
+}
+
+func TestBackendsAgree(t *testing.T) {
+	for i, input := range backendCases {
+		t.Run(fmt.Sprintf("Backend Test Case %d", i), func(t *testing.T) {
+			expected := evaluator.Eval(parse(input), object.NewEnvironment())
+			actual := runVM(t, input)
+
+			testObjectsEqual(t, input, actual, expected)
+		})
+	}
+}
+
+func TestImportsAgree(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"math.mk":    "let square = fn(x) { x * x }; export let cube = fn(x) { x * square(x) }; export let two = 2;",
+		"counter.mk": "let n = 0; export let next = fn() { n += 1 };",
+		"uses.mk":    `let math = import "math"; export let eight = math.cube(math.two);`,
+		"cycle.mk":   `export let self = import "cycle";`,
+		"fails.mk":   "export let x = 1 + true;",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatalf("Could not write module: %s", err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	inputs := []string{
+		fmt.Sprintf(`let m = import "%s"; m.cube(3)`, path("math")),
+		fmt.Sprintf(`(import "%s").eight`, path("uses")),
+		fmt.Sprintf(`let a = import "%s"; let b = import "%s"; [a.next(), b.next(), a.next()]`,
+			path("counter"), path("counter.mk")),
+		fmt.Sprintf(`let m = import "%s"; m.square`, path("math")),
+		fmt.Sprintf(`let m = import "%s"; let f = fn() { m.cube(m.two) }; f()`, path("math")),
+		fmt.Sprintf(`try { import "%s" } catch (e) { [e.kind, e.message] }`, path("missing")),
+		fmt.Sprintf(`try { import "%s" } catch (e) { e.message }`, path("cycle")),
+		fmt.Sprintf(`import "%s"`, path("fails")),
+	}
+
+	for i, input := range inputs {
+		t.Run(fmt.Sprintf("Import Test Case %d", i), func(t *testing.T) {
+			expected := evaluator.Eval(parse(input), object.NewEnvironment())
+			actual := runVM(t, input)
+
+			testObjectsEqual(t, input, actual, expected)
+		})
+	}
+}
+
+func TestRecursiveFibonacci(t *testing.T) {
+	input := `
+let fibonacci = fn(x) {
+	if (x == 0) {
+		return 0;
+	} else {
+		if (x == 1) {
+			return 1;
+		} else {
+			fibonacci(x - 1) + fibonacci(x - 2);
+		}
+	}
+};
+fibonacci(15);`
+
+	result, ok := runVM(t, input).(*object.Integer)
+	if !ok || result.Value != 610 {
+		t.Errorf("Incorrect fibonacci result, got %+v want %d", result, 610)
+	}
+}
+
+func TestCallingFunctionsWithWrongArguments(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`fn() { 1; }(1);`, "wrong number of arguments, got 1 want 0"},
+		{`fn(a) { a; }();`, "wrong number of arguments, got 0 want 1"},
+		{`fn(a, b) { a + b; }(1);`, "wrong number of arguments, got 1 want 2"},
+		{`let f = fn() { f() }; f()`, "stack overflow"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("Wrong Arguments Test Case %d", i), func(t *testing.T) {
+			errObj, ok := runVM(t, c.input).(*object.Error)
+			if !ok {
+				t.Fatalf("Object is not an error object")
+			}
+
+			if errObj.Message != c.expected {
+				t.Errorf("Incorrect error message, got %q want %q", errObj.Message, c.expected)
+			}
+		})
+	}
+}
+
+func TestGlobalsStore(t *testing.T) {
+	globals := make([]object.Object, GlobalsSize)
+	symbolTable := compiler.NewSymbolTableWithBuiltins()
+	constants := []object.Object{}
+
+	for _, input := range []string{"let a = 5;", "let b = a * 2;", "a + b"} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("Compiler error: %s", err)
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := NewWithGlobalsStore(bytecode, globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("VM error: %s", err)
+		}
+
+		if input == "a + b" {
+			result, ok := machine.LastPoppedStackElem().(*object.Integer)
+			if !ok || result.Value != 15 {
+				t.Errorf("Incorrect result, got %+v want %d", result, 15)
+			}
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	return p.ParseProgram()
+}
+
+// runVM compiles and runs input, reporting compilation errors as error
+// objects the same way the evaluator reports unknown identifiers.
+func runVM(t *testing.T, input string) object.Object {
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		compileErr, ok := err.(*compiler.Error)
+		if !ok {
+			t.Fatalf("Compiler error: %s", err)
+		}
+		return &object.Error{Message: compileErr.Message, Pos: compileErr.Pos}
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("VM error: %s", err)
+	}
+
+	return machine.LastPoppedStackElem()
+}
+
+func testObjectsEqual(t *testing.T, input string, actual, expected object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case *object.Error:
+		errObj, ok := actual.(*object.Error)
+		if !ok {
+			t.Errorf("%q: object is not an error, got %T (%+v)", input, actual, actual)
+			return
+		}
+
+		if errObj.Inspect() != expected.Inspect() {
+			t.Errorf("%q: incorrect error, got %q want %q", input, errObj.Inspect(), expected.Inspect())
+		}
+	case *object.Hash:
+		hash, ok := actual.(*object.Hash)
+		if !ok {
+			t.Errorf("%q: object is not a hash, got %T (%+v)", input, actual, actual)
+			return
+		}
+
+		if hash.Len() != expected.Len() {
+			t.Errorf("%q: incorrect amount of pairs, got %d want %d",
+				input, hash.Len(), expected.Len())
+			return
+		}
+
+		for i, expectedPair := range expected.Pairs() {
+			pair := hash.Pairs()[i]
+			if pair.Key.Inspect() != expectedPair.Key.Inspect() {
+				t.Errorf("%q: incorrect key at %d, got %s want %s",
+					input, i, pair.Key.Inspect(), expectedPair.Key.Inspect())
+				continue
+			}
+
+			testObjectsEqual(t, input, pair.Value, expectedPair.Value)
+		}
+	default:
+		if actual == nil || expected == nil {
+			if actual != expected {
+				t.Errorf("%q: incorrect result, got %+v want %+v", input, actual, expected)
+			}
+			return
+		}
+
+		if actual.Type() != expected.Type() || actual.Inspect() != expected.Inspect() {
+			t.Errorf("%q: incorrect result, got %s (%s) want %s (%s)", input,
+				actual.Inspect(), actual.Type(), expected.Inspect(), expected.Type())
+		}
+	}
+}